@@ -0,0 +1,228 @@
+package starstruct
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// UnpackArgs unpacks the positional and keyword arguments of a call to the
+// builtin named fnName into the fields of the struct pointed to by dst. It is
+// similar to starlark.UnpackArgs, but the parameters are the fields of the
+// struct and the arguments are decoded following the rules of FromStarlark,
+// so that e.g. a Dict argument can be decoded into a struct field.
+//
+// The parameters are the exported fields of the struct, in order, named
+// after their starlark struct tag name (or the field name, also matched in
+// lowercase as for FromStarlark, or the name returned by the naming strategy
+// set with the FieldNamingFrom option). Fields with the "-" name are ignored, and
+// the fields of an embedded struct (not a pointer to a struct) without a
+// starlark name are parameters as if they were part of the parent struct.
+// Arguments can be provided positionally or by keyword, and the following
+// options can be set in the struct tag to control how they are unpacked:
+//   - `starlark:"name,required"` for a parameter that must be provided (by
+//     default, parameters are optional and the fields of those that are not
+//     provided are unmodified, so that they can hold default values)
+//   - `starlark:"name,default=1"` for a parameter that is set to that default
+//     value if it is not provided, as described for FromStarlark
+//   - `starlark:"name,posonly"` for a parameter that can only be provided
+//     positionally
+//   - `starlark:"name,variadic"` for a slice field that receives the
+//     positional arguments that follow the other parameters, decoded as if
+//     they were a Tuple. The parameters after it can only be provided by
+//     keyword.
+//   - `starlark:",remain"` for a map field with string keys that receives the
+//     keyword arguments that do not match any parameter, decoded as if they
+//     were a Dict (see FromStarlark). It is not a parameter.
+//
+// Those options are not conversion options, which can be specified too, e.g.
+// `starlark:"name,required,asint=ms"`, as well as validation constraints
+// (see FromStarlark).
+//
+// The errors mention the function and parameter names as in the errors of
+// starlark.UnpackArgs, e.g. "fn: missing argument for name" or "fn: for
+// parameter name: ...", the latter wrapping the conversion errors (such as
+// TypeError) of the parameter. The arguments are decoded with the provided
+// options, as for FromStarlark. Once the arguments are unpacked, the
+// AfterDecode and Validate methods of the struct are called as for
+// FromStarlark.
+//
+// It panics if dst is not a non-nil pointer to a struct, if a variadic
+// field is not a slice or if there is more than one variadic field. An
+// invalid remain field fails with a TagError (as for FromStarlark).
+func UnpackArgs(fnName string, args starlark.Tuple, kwargs []starlark.Tuple, dst any, opts ...FromOption) error {
+	if dst == nil {
+		panic("destination value is not a pointer to a struct: nil")
+	}
+	rval := reflect.ValueOf(dst)
+	if !isStructPtrType(rval.Type()) {
+		panic(fmt.Sprintf("destination value is not a pointer to a struct: %s", rval.Type()))
+	}
+	if rval.IsNil() {
+		panic(fmt.Sprintf("destination value is a nil pointer: %s", rval.Type()))
+	}
+
+	var d decoder
+	for _, opt := range opts {
+		opt(&d)
+	}
+	return d.unpackArgs(fnName, args, kwargs, rval.Elem())
+}
+
+// unpacks the arguments into the fields of the struct strct, as described
+// for UnpackArgs, decoding them with the configuration of d.
+func (d *decoder) unpackArgs(fnName string, args starlark.Tuple, kwargs []starlark.Tuple, strct reflect.Value) error {
+	params := argParams("", strct, d.naming, nil)
+	variadic := -1
+	for i, p := range params {
+		if !p.variadic {
+			continue
+		}
+		if variadic >= 0 {
+			panic(fmt.Sprintf("more than one variadic field: %s and %s", params[variadic].path, p.path))
+		}
+		if p.fld.Kind() != reflect.Slice {
+			panic(fmt.Sprintf("variadic field is not a slice: %s", p.path))
+		}
+		variadic = i
+	}
+
+	// parameters that can be provided positionally
+	positional := params
+	if variadic >= 0 {
+		positional = params[:variadic]
+	}
+
+	r, hasRemain, err := remainField("", strct)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fnName, err)
+	}
+
+	vals := make([]starlark.Value, len(params))
+	if len(args) > len(positional) {
+		if variadic < 0 {
+			return fmt.Errorf("%s: got %d arguments, want at most %d", fnName, len(args), len(positional))
+		}
+		vals[variadic] = args[len(positional):]
+		args = args[:len(positional)]
+	}
+	copy(vals, args)
+
+	var rest *starlark.Dict
+	for _, kv := range kwargs {
+		nm, _ := starlark.AsString(kv[0])
+		ix := -1
+		for i, p := range params {
+			if p.name == nm || (p.tryLower && strings.ToLower(p.name) == nm) {
+				ix = i
+				break
+			}
+		}
+		if ix < 0 && hasRemain {
+			if rest == nil {
+				rest = starlark.NewDict(1)
+			}
+			_ = rest.SetKey(kv[0], kv[1]) // cannot fail, key is a string
+			continue
+		}
+		if ix < 0 || params[ix].posonly || params[ix].variadic {
+			return fmt.Errorf("%s: unexpected keyword argument %s", fnName, kv[0])
+		}
+		if vals[ix] != nil {
+			return fmt.Errorf("%s: got multiple values for keyword argument %s", fnName, kv[0])
+		}
+		vals[ix] = kv[1]
+	}
+
+	for i, p := range params {
+		if p.required && vals[i] == nil {
+			return fmt.Errorf("%s: missing argument for %s", fnName, p.name)
+		}
+	}
+
+	var errs []error
+	for i, p := range params {
+		pd := d.config()
+		if v, ok := pd.withDefault(p.path, vals[i], vals[i] != nil, p.fopts); ok {
+			pd.fromStarlarkValue(p.path, v, p.fld, p.opts)
+			if len(pd.errs) == 0 {
+				pd.validateField(p.path, p.fld, p.fopts)
+			}
+		}
+		if len(pd.errs) > 0 {
+			errs = append(errs, fmt.Errorf("%s: for parameter %s: %w", fnName, p.name, errors.Join(pd.errs...)))
+		}
+	}
+	if rest != nil {
+		pd := d.config()
+		pd.setFieldRemain(r, rest)
+		if len(pd.errs) > 0 {
+			errs = append(errs, fmt.Errorf("%s: %w", fnName, errors.Join(pd.errs...)))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	pd := d.config()
+	pd.afterDecode("", strct)
+	if len(pd.errs) > 0 {
+		return fmt.Errorf("%s: %w", fnName, errors.Join(pd.errs...))
+	}
+	return nil
+}
+
+// argParam is a parameter for UnpackArgs.
+type argParam struct {
+	name     string
+	tryLower bool
+	path     string
+	fld      reflect.Value
+	opts     tagOpt
+	fopts    []fieldOpt
+
+	required bool
+	posonly  bool
+	variadic bool
+}
+
+// returns the parameters for the fields of the struct strct, named with the
+// naming strategy ns, appended to params.
+func argParams(path string, strct reflect.Value, ns NamingStrategy, params []argParam) []argParam {
+	strctTyp := strct.Type()
+	count := strctTyp.NumField()
+	for i := 0; i < count; i++ {
+		fldTyp := strctTyp.Field(i)
+		nm, rawOpts, _ := strings.Cut(fldTyp.Tag.Get("starlark"), ",")
+		if !fldTyp.IsExported() || nm == "-" || isRemainTag(rawOpts) {
+			continue
+		}
+
+		path := path
+		if path != "" {
+			path += "."
+		}
+		path += fldTyp.Name
+		fld := strct.Field(i)
+
+		var tryLower bool
+		if nm == "" {
+			if fldTyp.Anonymous && fldTyp.Type.Kind() == reflect.Struct {
+				params = argParams(path, fld, ns, params)
+				continue
+			}
+			nm, tryLower = fieldKey(ns, fldTyp.Name)
+		}
+
+		opts, fopts := parseTagOpts(rawOpts)
+		p := argParam{name: nm, tryLower: tryLower, path: path, fld: fld, opts: opts, fopts: fopts}
+		_, p.required = findFieldOpt(fopts, "required")
+		_, p.posonly = findFieldOpt(fopts, "posonly")
+		_, p.variadic = findFieldOpt(fopts, "variadic")
+		params = append(params, p)
+	}
+	return params
+}
@@ -0,0 +1,168 @@
+package starstruct
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.starlark.net/starlark"
+)
+
+type ArgsBase struct {
+	Verbose bool `starlark:"verbose"`
+}
+
+type argsServe struct {
+	Addr    string        `starlark:"addr,required,posonly"`
+	Server  *bindServer   `starlark:"server"`
+	Timeout time.Duration `starlark:"timeout,asint=ms"`
+	Names   []string      `starlark:"names,variadic"`
+	Retries int           `starlark:"retries,required"`
+	ArgsBase
+	Port    int
+	Ignored int `starlark:"-"`
+}
+
+func TestUnpackArgs(t *testing.T) {
+	cases := []struct {
+		name   string
+		args   starlark.Tuple
+		kwargs []starlark.Tuple
+		want   argsServe
+	}{
+		{"required only", starlark.Tuple{starlark.String("a")}, []starlark.Tuple{
+			{starlark.String("retries"), starlark.MakeInt(3)},
+		}, argsServe{Addr: "a", Retries: 3, Port: -1}},
+
+		{"all positional", starlark.Tuple{
+			starlark.String("a"),
+			dict(M{"addr": starlark.String("b"), "port": starlark.MakeInt(80)}),
+			starlark.MakeInt(1000),
+			starlark.String("x"),
+			starlark.String("y"),
+		}, []starlark.Tuple{
+			{starlark.String("retries"), starlark.MakeInt(3)},
+			{starlark.String("verbose"), starlark.True},
+			{starlark.String("port"), starlark.MakeInt(8080)},
+		}, argsServe{
+			Addr:     "a",
+			Server:   &bindServer{Addr: "b", Port: 80},
+			Timeout:  time.Second,
+			Names:    []string{"x", "y"},
+			Retries:  3,
+			ArgsBase: ArgsBase{Verbose: true},
+			Port:     8080,
+		}},
+
+		{"keywords", starlark.Tuple{starlark.String("a")}, []starlark.Tuple{
+			{starlark.String("Port"), starlark.MakeInt(1)},
+			{starlark.String("timeout"), starlark.MakeInt(2)},
+			{starlark.String("retries"), starlark.MakeInt(3)},
+		}, argsServe{Addr: "a", Timeout: 2 * time.Millisecond, Retries: 3, Port: 1}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := argsServe{Port: -1, Ignored: 1}
+			err := UnpackArgs("serve", c.args, c.kwargs, &got)
+			require.NoError(t, err)
+			c.want.Ignored = 1
+			require.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestUnpackArgs_Errors(t *testing.T) {
+	retries := starlark.Tuple{starlark.String("retries"), starlark.MakeInt(1)}
+	cases := []struct {
+		name   string
+		args   starlark.Tuple
+		kwargs []starlark.Tuple
+		err    string
+	}{
+		{"missing posonly", nil, []starlark.Tuple{retries}, `serve: missing argument for addr`},
+		{"missing kwonly", starlark.Tuple{starlark.String("a")}, nil, `serve: missing argument for retries`},
+		{"posonly as keyword", nil, []starlark.Tuple{{starlark.String("addr"), starlark.String("a")}, retries},
+			`serve: unexpected keyword argument "addr"`},
+		{"variadic as keyword", starlark.Tuple{starlark.String("a")}, []starlark.Tuple{{starlark.String("names"), starlark.None}, retries},
+			`serve: unexpected keyword argument "names"`},
+		{"unknown keyword", starlark.Tuple{starlark.String("a")}, []starlark.Tuple{{starlark.String("nope"), starlark.None}},
+			`serve: unexpected keyword argument "nope"`},
+		{"multiple values", starlark.Tuple{starlark.String("a"), starlark.None}, []starlark.Tuple{{starlark.String("server"), starlark.None}},
+			`serve: got multiple values for keyword argument "server"`},
+		{"conversion", starlark.Tuple{starlark.MakeInt(1)}, []starlark.Tuple{retries},
+			`serve: for parameter addr: Addr: cannot convert Starlark int to Go type string`},
+		{"nested conversion", starlark.Tuple{starlark.String("a"), dict(M{"port": starlark.String("x")})}, []starlark.Tuple{retries},
+			`serve: for parameter server: Server.Port: cannot convert Starlark string to Go type uint16`},
+		{"variadic conversion", starlark.Tuple{starlark.String("a"), starlark.None, starlark.None, starlark.String("x"), starlark.MakeInt(1)}, []starlark.Tuple{retries},
+			`serve: for parameter names: Names[1]: cannot convert Starlark int to Go type string`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got argsServe
+			err := UnpackArgs("serve", c.args, c.kwargs, &got)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.err)
+		})
+	}
+
+	t.Run("too many args", func(t *testing.T) {
+		var got struct {
+			A int `starlark:"a"`
+		}
+		err := UnpackArgs("fn", starlark.Tuple{starlark.MakeInt(1), starlark.MakeInt(2)}, nil, &got)
+		require.EqualError(t, err, "fn: got 2 arguments, want at most 1")
+	})
+
+	t.Run("error types", func(t *testing.T) {
+		var got argsServe
+		err := UnpackArgs("serve", starlark.Tuple{starlark.MakeInt(1), starlark.MakeInt(2)}, []starlark.Tuple{retries}, &got)
+		require.Error(t, err)
+		var te *TypeError
+		require.ErrorAs(t, err, &te)
+		require.Equal(t, "Addr", te.Path)
+		require.Contains(t, err.Error(), "serve: for parameter server: Server: cannot convert Starlark int to Go type *starstruct.bindServer")
+	})
+}
+
+func TestUnpackArgs_Builtin(t *testing.T) {
+	var got argsServe
+	serve := starlark.NewBuiltin("serve", func(th *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if err := UnpackArgs(b.Name(), args, kwargs, &got); err != nil {
+			return nil, err
+		}
+		return starlark.None, nil
+	})
+
+	var th starlark.Thread
+	_, err := starlark.ExecFile(&th, "test", `serve("a", {"addr": "b"}, retries=2)`, starlark.StringDict{"serve": serve})
+	require.NoError(t, err)
+	require.Equal(t, argsServe{Addr: "a", Server: &bindServer{Addr: "b"}, Retries: 2}, got)
+
+	_, err = starlark.ExecFile(&th, "test", `serve(1, retries=2)`, starlark.StringDict{"serve": serve})
+	require.Error(t, err)
+	var ee *starlark.EvalError
+	require.ErrorAs(t, err, &ee)
+	require.Contains(t, ee.Backtrace(), "test:1:6: in <toplevel>")
+}
+
+func TestUnpackArgs_Panics(t *testing.T) {
+	require.PanicsWithValue(t, "destination value is not a pointer to a struct: nil", func() {
+		_ = UnpackArgs("fn", nil, nil, nil)
+	})
+	require.PanicsWithValue(t, "destination value is a nil pointer: *starstruct.argsServe", func() {
+		_ = UnpackArgs("fn", nil, nil, (*argsServe)(nil))
+	})
+	require.PanicsWithValue(t, "variadic field is not a slice: A", func() {
+		_ = UnpackArgs("fn", nil, nil, &struct {
+			A int `starlark:"a,variadic"`
+		}{})
+	})
+	require.PanicsWithValue(t, "more than one variadic field: A and B", func() {
+		_ = UnpackArgs("fn", nil, nil, &struct {
+			A []int `starlark:"a,variadic"`
+			B []int `starlark:"b,variadic"`
+		}{})
+	})
+}
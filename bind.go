@@ -0,0 +1,754 @@
+package starstruct
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.starlark.net/starlark"
+)
+
+// Bind returns a starlark value that exposes the Go struct pointed to by ptr
+// directly to starlark, without copying it. Reading a field (with the
+// attribute syntax, e.g. cfg.server, or the dictionary syntax, e.g.
+// cfg["server"]) returns its current value, and assigning a field sets it on
+// the Go struct, so that the changes are immediately visible on both sides.
+//
+// The fields are named as in ToStarlark, following the struct tags (or the
+// naming strategy set with the FieldNamingFrom option), and embedded structs
+// are supported in the same way. Nested structs, slices, arrays and maps
+// (that would be converted to a Dict) are returned as bound values too, so
+// that e.g. cfg.server.ports[0] = 80 modifies the Go value. Bound slices
+// support the append and extend methods (and, as a list, cannot be modified
+// while they are iterated over), and bound maps the get, items, keys and
+// values methods. Other values are converted as in ToStarlark, and as such
+// are copies (this includes map values that are not pointers).
+//
+// An assigned starlark value is converted as in FromStarlark with the
+// provided options, into a new value that replaces the existing one only if
+// the conversion succeeded. Otherwise the assignment fails with the
+// conversion errors (such as TypeError or NumberError), which the starlark
+// interpreter reports at the position of the assignment in the script.
+//
+// The remain field of a struct (with the remain struct tag option) is not
+// bound: its entries are neither exposed as fields nor set by assigning
+// unknown fields, which fail as for any other bound struct.
+//
+// Bound values are never frozen, as they reflect Go values that can be
+// modified at any time from Go. It panics if ptr is not a non-nil pointer to
+// an addressable and settable struct, or if the struct tag options of its
+// fields are invalid (e.g. a constraint that does not apply to the type of
+// the field).
+func Bind(ptr any, opts ...FromOption) starlark.Value {
+	if ptr == nil {
+		panic("bound value is not a pointer to a struct: nil")
+	}
+
+	rval := reflect.ValueOf(ptr)
+	if !isStructPtrType(rval.Type()) {
+		panic(fmt.Sprintf("bound value is not a pointer to a struct: %s", rval.Type()))
+	}
+	if rval.IsNil() {
+		panic(fmt.Sprintf("bound value is a nil pointer: %s", rval.Type()))
+	}
+
+	oriVal := rval
+	rval = rval.Elem()
+	if !rval.CanAddr() || !rval.CanSet() {
+		panic(fmt.Sprintf("bound value is a pointer to an unaddressable or unsettable struct: %s", oriVal.Type()))
+	}
+	mustCheckTags(rval.Type())
+
+	var d decoder
+	for _, opt := range opts {
+		opt(&d)
+	}
+	return &boundStruct{v: rval, bnd: &binding{dec: d.config()}}
+}
+
+var (
+	_ starlark.HasAttrs    = (*boundStruct)(nil)
+	_ starlark.HasSetField = (*boundStruct)(nil)
+	_ starlark.HasSetKey   = (*boundStruct)(nil)
+	_ starlark.HasSetIndex = (*boundSlice)(nil)
+	_ starlark.HasAttrs    = (*boundSlice)(nil)
+	_ starlark.HasSetKey   = (*boundMap)(nil)
+	_ starlark.HasAttrs    = (*boundMap)(nil)
+)
+
+// binding is the state shared by a bound value and the bound values of its
+// fields, elements and map values.
+type binding struct {
+	// configuration of the decoder used to convert the assigned values, its
+	// naming strategy is also used to name the fields.
+	dec decoder
+
+	canFreeze bool
+	frozen    bool
+
+	// the bound slices that are being iterated over, by address of the Go
+	// slice or array. As a new bound slice is returned each time a field is
+	// read, this is the one that holds the count of active iterators of that
+	// Go value.
+	mu        sync.Mutex
+	iterating map[boundSliceKey]*boundSlice
+}
+
+type boundSliceKey struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// returns the encoder used to convert the values that are not bound.
+func (bnd *binding) encoder() encoder {
+	return encoder{naming: bnd.dec.naming}
+}
+
+// sets the frozen state to true, if the bound value can be frozen.
+func (bnd *binding) freeze() {
+	if bnd.canFreeze {
+		bnd.frozen = true
+	}
+}
+
+// returns an error if the bound value of type typ cannot be modified because
+// it is frozen, verb describing the attempted modification.
+func (bnd *binding) checkFrozen(verb, typ string) error {
+	if bnd.frozen {
+		return fmt.Errorf("cannot %s frozen %s", verb, typ)
+	}
+	return nil
+}
+
+// returns the starlark value for the Go value v at path. Structs, slices,
+// arrays and maps that can be modified in place are returned as bound values
+// that share the binding, other values are converted as in ToStarlark.
+func (bnd *binding) value(path string, v reflect.Value, opts tagOpt) (starlark.Value, error) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return starlark.None, nil
+		}
+		if isBindableType(v.Type().Elem(), opts.current()) {
+			return bnd.value(path, v.Elem(), opts)
+		}
+	case reflect.Interface:
+		if !v.IsNil() && v.Elem().Kind() == reflect.Pointer {
+			return bnd.value(path, v.Elem(), opts)
+		}
+	}
+
+	if v.CanAddr() && isBindableType(v.Type(), opts.current()) {
+		switch v.Kind() {
+		case reflect.Struct:
+			return &boundStruct{path: path, v: v, bnd: bnd}, nil
+		case reflect.Slice, reflect.Array:
+			return &boundSlice{path: path, v: v, opts: opts, bnd: bnd}, nil
+		case reflect.Map:
+			return &boundMap{path: path, v: v, opts: opts, bnd: bnd}, nil
+		}
+	}
+
+	e := bnd.encoder()
+	sval := e.convertGoValue(path, v, opts)
+	if len(e.errs) > 0 {
+		return nil, errors.Join(e.errs...)
+	}
+	return sval, nil
+}
+
+// returns true if a Go value of type t, converted with the tag option opt,
+// can be bound.
+func isBindableType(t reflect.Type, opt string) bool {
+	if t.Implements(starlarkValueType) || isStarlarkMarshalerType(t) || isMarshalerType(t) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return t != timeType && !isBigNumType(t)
+	case reflect.Slice, reflect.Array:
+		if opt == "aslist" {
+			return true
+		}
+		return opt == "" && !isByteSliceType(t) && !isByteArrayType(t)
+	case reflect.Map:
+		return isDictMapType(t) && (opt == "asdict" || !isSetMapType(t))
+	default:
+		return false
+	}
+}
+
+// sets the Go value dst at path to the starlark value v, converted as in
+// FromStarlark. The value is converted into a new Go value that replaces dst
+// only if the conversion succeeds and satisfies the constraints in fopts.
+func (bnd *binding) assign(path string, dst reflect.Value, v starlark.Value, opts tagOpt, fopts []fieldOpt) error {
+	newVal := reflect.New(dst.Type()).Elem()
+	d := bnd.dec
+	d.fromStarlarkValue(path, v, newVal, opts)
+	if len(d.errs) == 0 {
+		d.validateField(path, newVal, fopts)
+	}
+	if len(d.errs) > 0 {
+		return errors.Join(d.errs...)
+	}
+	dst.Set(newVal)
+	return nil
+}
+
+// returns the string representation of the Go value v, as converted by
+// ToStarlark.
+func (bnd *binding) string(path string, v reflect.Value, opts tagOpt) string {
+	e := bnd.encoder()
+	e.unsupported = UnsupportedSkip
+	if sval := e.convertGoValue(path, v, opts); sval != nil {
+		return sval.String()
+	}
+	return "None"
+}
+
+// boundStruct is the bound starlark value of a Go struct.
+type boundStruct struct {
+	path string
+	v    reflect.Value
+	bnd  *binding
+}
+
+// boundField is a field of a bound struct.
+type boundField struct {
+	name  string
+	path  string // the Go struct path, relative to the bound struct
+	index []int
+	opts  tagOpt
+	fopts []fieldOpt
+	lower bool // if true, the name can also be matched in all lowercase
+}
+
+// returns the fields of the struct type t, named with the naming strategy ns,
+// in the same way as they are walked by ToStarlark and FromStarlark. The
+// remain fields are skipped, their entries are not exposed.
+func boundFields(t reflect.Type, ns NamingStrategy) []boundField {
+	var fields []boundField
+
+	var walk func(path string, t reflect.Type, index []int)
+	walk = func(path string, t reflect.Type, index []int) {
+		count := t.NumField()
+		for i := 0; i < count; i++ {
+			fldTyp := t.Field(i)
+			nm, rawOpts, _ := strings.Cut(fldTyp.Tag.Get("starlark"), ",")
+			if !fldTyp.IsExported() || nm == "-" || isRemainTag(rawOpts) {
+				continue
+			}
+
+			path := path
+			if path != "" {
+				path += "."
+			}
+			path += fldTyp.Name
+			index := append(index[:len(index):len(index)], i)
+
+			var lower bool
+			if nm == "" {
+				if fldTyp.Anonymous {
+					if isStructOrPtrType(fldTyp.Type) {
+						typ := fldTyp.Type
+						if typ.Kind() == reflect.Pointer {
+							typ = typ.Elem()
+						}
+						walk(path, typ, index)
+					}
+					continue
+				}
+				nm, lower = fieldKey(ns, fldTyp.Name)
+			}
+
+			opts, fopts := parseTagOpts(rawOpts)
+			fields = append(fields, boundField{name: nm, path: path, index: index, opts: opts, fopts: fopts, lower: lower})
+		}
+	}
+	walk("", t, nil)
+	return fields
+}
+
+// returns the field matching the starlark name nm, following the same rules
+// as FromStarlark. If multiple fields have the same name, the last one is
+// returned, as it is the one that ToStarlark would store.
+func (b *boundStruct) field(nm string) (boundField, bool) {
+	var found, lower *boundField
+	fields := boundFields(b.v.Type(), b.bnd.dec.naming)
+	for i := range fields {
+		f := &fields[i]
+		if f.name == nm {
+			found = f
+		} else if f.lower && strings.ToLower(f.name) == nm {
+			lower = f
+		}
+	}
+	if found == nil {
+		found = lower
+	}
+	if found == nil {
+		return boundField{}, false
+	}
+	return *found, true
+}
+
+// returns the Go value of the field f. If alloc is true, nil embedded
+// pointers are allocated, otherwise it returns false if the field is
+// unreachable through a nil embedded pointer.
+func (b *boundStruct) fieldValue(f boundField, alloc bool) (reflect.Value, bool) {
+	v := b.v
+	for i, x := range f.index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func (b *boundStruct) fieldPath(f boundField) string {
+	if b.path == "" {
+		return f.path
+	}
+	return b.path + "." + f.path
+}
+
+func (b *boundStruct) String() string        { return b.bnd.string(b.path, b.v, nil) }
+func (b *boundStruct) Type() string          { return b.v.Type().String() }
+func (b *boundStruct) Freeze()               { b.bnd.freeze() }
+func (b *boundStruct) Truth() starlark.Bool  { return starlark.True }
+func (b *boundStruct) Hash() (uint32, error) { return 0, fmt.Errorf("unhashable type: %s", b.Type()) }
+
+// Attr returns the value of the field with the starlark name nm, or nil if
+// there is no such field.
+func (b *boundStruct) Attr(nm string) (starlark.Value, error) {
+	f, ok := b.field(nm)
+	if !ok {
+		return nil, nil
+	}
+	v, ok := b.fieldValue(f, false)
+	if !ok {
+		return starlark.None, nil
+	}
+	return b.bnd.value(b.fieldPath(f), v, f.opts)
+}
+
+// AttrNames returns the sorted starlark names of the fields.
+func (b *boundStruct) AttrNames() []string {
+	fields := boundFields(b.v.Type(), b.bnd.dec.naming)
+	names := make([]string, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if !seen[f.name] {
+			seen[f.name] = true
+			names = append(names, f.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetField sets the field with the starlark name nm to v.
+func (b *boundStruct) SetField(nm string, v starlark.Value) error {
+	f, ok := b.field(nm)
+	if !ok {
+		return starlark.NoSuchAttrError(fmt.Sprintf("%s has no .%s field", b.Type(), nm))
+	}
+	if err := b.bnd.checkFrozen("assign to field of", b.Type()); err != nil {
+		return err
+	}
+	fld, _ := b.fieldValue(f, true)
+	return b.bnd.assign(b.fieldPath(f), fld, v, f.opts, f.fopts)
+}
+
+// Get returns the value of the field with the starlark name k, which must be
+// a String.
+func (b *boundStruct) Get(k starlark.Value) (starlark.Value, bool, error) {
+	s, ok := k.(starlark.String)
+	if !ok {
+		return nil, false, nil
+	}
+	v, err := b.Attr(string(s))
+	if err != nil || v == nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+// SetKey sets the field with the starlark name k, which must be a String, to
+// v.
+func (b *boundStruct) SetKey(k, v starlark.Value) error {
+	s, ok := k.(starlark.String)
+	if !ok {
+		return fmt.Errorf("%s key must be a string, got %s", b.Type(), k.Type())
+	}
+	if _, ok := b.field(string(s)); !ok {
+		return fmt.Errorf("%s has no field %s", b.Type(), k.String())
+	}
+	return b.SetField(string(s), v)
+}
+
+// boundSlice is the bound starlark value of a Go slice or array.
+type boundSlice struct {
+	path string
+	v    reflect.Value
+	opts tagOpt
+	bnd  *binding
+
+	itercount uint32 // number of active iterators (ignoring frozen)
+}
+
+func (b *boundSlice) String() string        { return b.bnd.string(b.path, b.v, b.opts) }
+func (b *boundSlice) Type() string          { return b.v.Type().String() }
+func (b *boundSlice) Freeze()               { b.bnd.freeze() }
+func (b *boundSlice) Truth() starlark.Bool  { return b.Len() > 0 }
+func (b *boundSlice) Hash() (uint32, error) { return 0, fmt.Errorf("unhashable type: %s", b.Type()) }
+func (b *boundSlice) Len() int              { return b.v.Len() }
+
+func (b *boundSlice) elemPath(i int) string {
+	return fmt.Sprintf("%s[%d]", b.path, i)
+}
+
+// Index returns the value at index i. If it cannot be converted, None is
+// returned.
+func (b *boundSlice) Index(i int) starlark.Value {
+	v, err := b.bnd.value(b.elemPath(i), b.v.Index(i), b.opts.shift())
+	if err != nil {
+		return starlark.None
+	}
+	return v
+}
+
+// SetIndex sets the value at index i to v.
+func (b *boundSlice) SetIndex(i int, v starlark.Value) error {
+	if err := b.checkMutable("assign to element of"); err != nil {
+		return err
+	}
+	return b.bnd.assign(b.elemPath(i), b.v.Index(i), v, b.opts.shift(), nil)
+}
+
+// Iterate returns an iterator over the elements. As for a starlark List, the
+// slice cannot be modified while it is being iterated over.
+func (b *boundSlice) Iterate() starlark.Iterator {
+	it := b.iterating()
+	return &boundIterator{index: b.Index, len: b.Len, done: func() {
+		b.bnd.mu.Lock()
+		defer b.bnd.mu.Unlock()
+		if it.itercount--; it.itercount == 0 {
+			delete(b.bnd.iterating, it.key())
+		}
+	}}
+}
+
+func (b *boundSlice) key() boundSliceKey {
+	return boundSliceKey{addr: b.v.Addr().Pointer(), typ: b.v.Type()}
+}
+
+// increments the iterator count of the bound slice that holds it for the Go
+// value of b, which is b itself if that value is not already being iterated
+// over, and returns that bound slice.
+func (b *boundSlice) iterating() *boundSlice {
+	b.bnd.mu.Lock()
+	defer b.bnd.mu.Unlock()
+
+	key := b.key()
+	it := b.bnd.iterating[key]
+	if it == nil {
+		if b.bnd.iterating == nil {
+			b.bnd.iterating = make(map[boundSliceKey]*boundSlice)
+		}
+		it = b
+		b.bnd.iterating[key] = it
+	}
+	it.itercount++
+	return it
+}
+
+// returns an error if the slice is frozen or is being iterated over, verb
+// describing the attempted modification.
+func (b *boundSlice) checkMutable(verb string) error {
+	if err := b.bnd.checkFrozen(verb, b.Type()); err != nil {
+		return err
+	}
+
+	b.bnd.mu.Lock()
+	defer b.bnd.mu.Unlock()
+	if it := b.bnd.iterating[b.key()]; it != nil && it.itercount > 0 {
+		return fmt.Errorf("cannot %s %s during iteration", verb, b.Type())
+	}
+	return nil
+}
+
+var boundSliceMethods = map[string]func(*boundSlice, starlark.Tuple, []starlark.Tuple, string) (starlark.Value, error){
+	"append": (*boundSlice).append,
+	"extend": (*boundSlice).extend,
+}
+
+// Attr returns the methods of the bound slice. Arrays have no methods.
+func (b *boundSlice) Attr(nm string) (starlark.Value, error) {
+	if b.v.Kind() != reflect.Slice {
+		return nil, nil
+	}
+	method, ok := boundSliceMethods[nm]
+	if !ok {
+		return nil, nil
+	}
+	return starlark.NewBuiltin(nm, func(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		return method(b, args, kwargs, fn.Name())
+	}), nil
+}
+
+func (b *boundSlice) AttrNames() []string {
+	if b.v.Kind() != reflect.Slice {
+		return nil
+	}
+	return []string{"append", "extend"}
+}
+
+func (b *boundSlice) append(args starlark.Tuple, kwargs []starlark.Tuple, fnName string) (starlark.Value, error) {
+	var v starlark.Value
+	if err := starlark.UnpackPositionalArgs(fnName, args, kwargs, 1, &v); err != nil {
+		return nil, err
+	}
+	return starlark.None, b.appendValues(starlark.Tuple{v})
+}
+
+func (b *boundSlice) extend(args starlark.Tuple, kwargs []starlark.Tuple, fnName string) (starlark.Value, error) {
+	var iter starlark.Iterable
+	if err := starlark.UnpackPositionalArgs(fnName, args, kwargs, 1, &iter); err != nil {
+		return nil, err
+	}
+
+	// the iteration must be done before appending, in case iter is the slice
+	// itself.
+	var vals starlark.Tuple
+	it := iter.Iterate()
+	var v starlark.Value
+	for it.Next(&v) {
+		vals = append(vals, v)
+	}
+	it.Done()
+	return starlark.None, b.appendValues(vals)
+}
+
+// appends the values to the slice, only if they can all be converted.
+func (b *boundSlice) appendValues(vals starlark.Tuple) error {
+	if err := b.checkMutable("append to"); err != nil {
+		return err
+	}
+	n := b.v.Len()
+	newVals := make([]reflect.Value, len(vals))
+	for i, v := range vals {
+		newVals[i] = reflect.New(b.v.Type().Elem()).Elem()
+		d := b.bnd.dec
+		d.fromStarlarkValue(b.elemPath(n+i), v, newVals[i], b.opts.shift())
+		if len(d.errs) > 0 {
+			return errors.Join(d.errs...)
+		}
+	}
+	b.v.Set(reflect.Append(b.v, newVals...))
+	return nil
+}
+
+// boundMap is the bound starlark value of a Go map.
+type boundMap struct {
+	path string
+	v    reflect.Value
+	opts tagOpt
+	bnd  *binding
+}
+
+func (b *boundMap) String() string        { return b.bnd.string(b.path, b.v, b.opts) }
+func (b *boundMap) Type() string          { return b.v.Type().String() }
+func (b *boundMap) Freeze()               { b.bnd.freeze() }
+func (b *boundMap) Truth() starlark.Bool  { return b.Len() > 0 }
+func (b *boundMap) Hash() (uint32, error) { return 0, fmt.Errorf("unhashable type: %s", b.Type()) }
+func (b *boundMap) Len() int              { return b.v.Len() }
+
+func (b *boundMap) keyPath(k starlark.Value) string {
+	return fmt.Sprintf("%s[%s]", b.path, k.String())
+}
+
+// returns the starlark keys of the map, in sorted order.
+func (b *boundMap) keys() []starlark.Value {
+	e := b.bnd.encoder()
+	e.unsupported = UnsupportedSkip
+	keys := sortedMapKeys(b.v)
+	skeys := make([]starlark.Value, 0, len(keys))
+	for _, k := range keys {
+		if sk := e.convertGoKey(b.path, k); sk != nil {
+			skeys = append(skeys, sk)
+		}
+	}
+	return skeys
+}
+
+// Get returns the value for the key k. A key that cannot be converted to the
+// Go map's key type is not found.
+func (b *boundMap) Get(k starlark.Value) (starlark.Value, bool, error) {
+	key := reflect.New(b.v.Type().Key()).Elem()
+	d := b.bnd.dec
+	d.fromStarlarkKey(b.keyPath(k), k, key)
+	if len(d.errs) > 0 {
+		return nil, false, nil
+	}
+	v := b.v.MapIndex(key)
+	if !v.IsValid() {
+		return nil, false, nil
+	}
+	sv, err := b.bnd.value(b.keyPath(k), v, b.opts.shift())
+	if err != nil {
+		return nil, false, err
+	}
+	return sv, true, nil
+}
+
+// SetKey sets the value for the key k to v, allocating the map if it is nil.
+func (b *boundMap) SetKey(k, v starlark.Value) error {
+	if err := b.bnd.checkFrozen("insert into", b.Type()); err != nil {
+		return err
+	}
+
+	path := b.keyPath(k)
+	key := reflect.New(b.v.Type().Key()).Elem()
+	elem := reflect.New(b.v.Type().Elem()).Elem()
+
+	d := b.bnd.dec
+	d.fromStarlarkKey(path, k, key)
+	if len(d.errs) == 0 && !key.Comparable() {
+		d.recordTypeErr(path, k, key)
+	}
+	d.fromStarlarkValue(path, v, elem, b.opts.shift())
+	if len(d.errs) > 0 {
+		return errors.Join(d.errs...)
+	}
+
+	if b.v.IsNil() {
+		b.v.Set(reflect.MakeMap(b.v.Type()))
+	}
+	b.v.SetMapIndex(key, elem)
+	return nil
+}
+
+func (b *boundMap) Iterate() starlark.Iterator {
+	keys := b.keys()
+	return &boundIterator{
+		index: func(i int) starlark.Value { return keys[i] },
+		len:   func() int { return len(keys) },
+	}
+}
+
+func (b *boundMap) Items() []starlark.Tuple {
+	keys := b.keys()
+	items := make([]starlark.Tuple, 0, len(keys))
+	for _, k := range keys {
+		if v, ok, _ := b.Get(k); ok {
+			items = append(items, starlark.Tuple{k, v})
+		}
+	}
+	return items
+}
+
+var boundMapMethods = map[string]func(*boundMap, starlark.Tuple, []starlark.Tuple, string) (starlark.Value, error){
+	"get":    (*boundMap).get,
+	"items":  (*boundMap).items,
+	"keys":   (*boundMap).keysMethod,
+	"values": (*boundMap).values,
+}
+
+// Attr returns the methods of the bound map.
+func (b *boundMap) Attr(nm string) (starlark.Value, error) {
+	method, ok := boundMapMethods[nm]
+	if !ok {
+		return nil, nil
+	}
+	return starlark.NewBuiltin(nm, func(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		return method(b, args, kwargs, fn.Name())
+	}), nil
+}
+
+func (b *boundMap) AttrNames() []string {
+	return []string{"get", "items", "keys", "values"}
+}
+
+func (b *boundMap) get(args starlark.Tuple, kwargs []starlark.Tuple, fnName string) (starlark.Value, error) {
+	var k starlark.Value
+	var dflt starlark.Value = starlark.None
+	if err := starlark.UnpackPositionalArgs(fnName, args, kwargs, 1, &k, &dflt); err != nil {
+		return nil, err
+	}
+	v, ok, err := b.Get(k)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return dflt, nil
+	}
+	return v, nil
+}
+
+func (b *boundMap) items(args starlark.Tuple, kwargs []starlark.Tuple, fnName string) (starlark.Value, error) {
+	if err := starlark.UnpackPositionalArgs(fnName, args, kwargs, 0); err != nil {
+		return nil, err
+	}
+	items := b.Items()
+	vals := make([]starlark.Value, len(items))
+	for i, item := range items {
+		vals[i] = item
+	}
+	return starlark.NewList(vals), nil
+}
+
+func (b *boundMap) keysMethod(args starlark.Tuple, kwargs []starlark.Tuple, fnName string) (starlark.Value, error) {
+	if err := starlark.UnpackPositionalArgs(fnName, args, kwargs, 0); err != nil {
+		return nil, err
+	}
+	return starlark.NewList(b.keys()), nil
+}
+
+func (b *boundMap) values(args starlark.Tuple, kwargs []starlark.Tuple, fnName string) (starlark.Value, error) {
+	if err := starlark.UnpackPositionalArgs(fnName, args, kwargs, 0); err != nil {
+		return nil, err
+	}
+	items := b.Items()
+	vals := make([]starlark.Value, len(items))
+	for i, item := range items {
+		vals[i] = item[1]
+	}
+	return starlark.NewList(vals), nil
+}
+
+// boundIterator iterates over the values of a bound slice or the keys of a
+// bound map.
+type boundIterator struct {
+	index func(int) starlark.Value
+	len   func() int
+	done  func()
+	i     int
+}
+
+func (it *boundIterator) Next(p *starlark.Value) bool {
+	if it.i >= it.len() {
+		return false
+	}
+	*p = it.index(it.i)
+	it.i++
+	return true
+}
+
+func (it *boundIterator) Done() {
+	if it.done != nil {
+		it.done()
+		it.done = nil
+	}
+}
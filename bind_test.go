@@ -0,0 +1,272 @@
+package starstruct
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.starlark.net/starlark"
+)
+
+type bindServer struct {
+	Addr  string         `starlark:"addr"`
+	Port  uint16         `starlark:"port"`
+	Tags  []string       `starlark:"tags"`
+	Limit map[string]int `starlark:"limits"`
+}
+
+type BindBase struct {
+	Name string `starlark:"name"`
+}
+
+type bindConfig struct {
+	BindBase
+	Server   bindServer             `starlark:"server"`
+	Backup   *bindServer            `starlark:"backup"`
+	Servers  map[string]*bindServer `starlark:"servers"`
+	Timeout  time.Duration          `starlark:"timeout"`
+	Enabled  bool
+	Ratio    float64 `starlark:"ratio"`
+	Pair     [2]int  `starlark:"pair"`
+	Ignored  string  `starlark:"-"`
+	internal string
+}
+
+func execBound(t *testing.T, script string, cfg *bindConfig) error {
+	t.Helper()
+	globals := starlark.StringDict{"cfg": Bind(cfg)}
+	var th starlark.Thread
+	_, err := starlark.ExecFile(&th, "test", script, globals)
+	return err
+}
+
+func TestBind(t *testing.T) {
+	const script = `
+cfg.name = cfg.name + "!"
+cfg.server.addr = "localhost"
+cfg["server"]["port"] = cfg.server.port + 1
+cfg.server.tags.append("b")
+cfg.server.tags.extend(["c", "d"])
+cfg.server.tags[0] = "A"
+cfg.server.limits["x"] = 10
+cfg.backup = {"addr": "backup", "port": 2}
+srv = cfg.servers["a"]
+srv.port = 3
+cfg.servers["b"] = {"addr": "b"}
+cfg.timeout = "5s"
+cfg.enabled = True
+cfg.pair[1] = len(cfg.server.tags)
+
+if [k for k in cfg.servers] != ["a", "b"] or len(cfg.servers.items()) != 2:
+	fail("unexpected keys")
+if cfg.servers.get("z") != None or "port" not in dir(cfg.server):
+	fail("unexpected value")
+`
+
+	cfg := bindConfig{
+		BindBase: BindBase{Name: "n"},
+		Server:   bindServer{Port: 80, Tags: []string{"a"}},
+		Servers:  map[string]*bindServer{"a": {Addr: "a"}},
+		Ignored:  "i",
+	}
+	globals := starlark.StringDict{"cfg": Bind(&cfg)}
+	var th starlark.Thread
+	_, err := starlark.ExecFile(&th, "test", script, globals)
+	require.NoError(t, err)
+
+	require.Equal(t, bindConfig{
+		BindBase: BindBase{Name: "n!"},
+		Server: bindServer{
+			Addr:  "localhost",
+			Port:  81,
+			Tags:  []string{"A", "b", "c", "d"},
+			Limit: map[string]int{"x": 10},
+		},
+		Backup: &bindServer{Addr: "backup", Port: 2},
+		Servers: map[string]*bindServer{
+			"a": {Addr: "a", Port: 3},
+			"b": {Addr: "b"},
+		},
+		Timeout: 5 * time.Second,
+		Enabled: true,
+		Pair:    [2]int{0, 4},
+		Ignored: "i",
+	}, cfg)
+}
+
+func TestBind_LiveValues(t *testing.T) {
+	const script = `
+srv = cfg.server
+tags = srv.tags
+def update():
+	srv.port = 8080
+	tags.append("late")
+`
+
+	cfg := bindConfig{Server: bindServer{Limit: map[string]int{"a": 1}}}
+	globals := starlark.StringDict{"cfg": Bind(&cfg)}
+	var th starlark.Thread
+	mod, err := starlark.ExecFile(&th, "test", script, globals)
+	require.NoError(t, err)
+
+	// changes made from Go are visible to starlark
+	cfg.Server.Addr = "changed"
+	addr, err := mod["srv"].(starlark.HasAttrs).Attr("addr")
+	require.NoError(t, err)
+	require.Equal(t, starlark.String("changed"), addr)
+
+	// changes made through aliases are visible to Go
+	_, err = starlark.Call(&th, mod["update"], nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint16(8080), cfg.Server.Port)
+	require.Equal(t, []string{"late"}, cfg.Server.Tags)
+
+	// bound values can be decoded
+	var out struct {
+		Server bindServer `starlark:"srv"`
+	}
+	require.NoError(t, FromStarlark(starlark.StringDict{"srv": mod["srv"]}, &out))
+	require.Equal(t, cfg.Server, out.Server)
+}
+
+func TestBind_Errors(t *testing.T) {
+	cases := []struct {
+		name   string
+		script string
+		err    string
+		check  func(*testing.T, error)
+	}{
+		{"type error", "\ncfg.server.port = 'x'", `Server.Port: cannot convert Starlark string to Go type uint16`, func(t *testing.T, err error) {
+			var te *TypeError
+			require.ErrorAs(t, err, &te)
+			require.Equal(t, "Server.Port", te.Path)
+			var ee *starlark.EvalError
+			require.ErrorAs(t, err, &ee)
+			require.Contains(t, ee.Backtrace(), "test:2:11: in <toplevel>")
+		}},
+		{"number error", "cfg.server.port = 100000", `Server.Port: cannot assign Starlark int to Go type uint16: value out of range`, func(t *testing.T, err error) {
+			var ne *NumberError
+			require.ErrorAs(t, err, &ne)
+		}},
+		{"slice element", "cfg.server.tags.append(1)", `Server.Tags[0]: cannot convert Starlark int to Go type string`, nil},
+		{"map key", "cfg.server.limits[1] = 1", `Server.Limit[1]: cannot convert Starlark int to Go type string`, nil},
+		{"map value", "cfg.servers['a'] = 1", `Servers["a"]: cannot convert Starlark int to Go type *starstruct.bindServer`, nil},
+		{"parse error", "cfg.timeout = 'abc'", `Timeout: cannot convert Starlark string to Go type time.Duration`, nil},
+		{"unknown field", "cfg.nope = 1", `starstruct.bindConfig has no .nope field`, nil},
+		{"unknown key", "cfg['nope'] = 1", `starstruct.bindConfig has no field "nope"`, nil},
+		{"unexported field", "cfg.internal = 'x'", `has no .internal field`, nil},
+		{"ignored field", "cfg.ignored", `has no .ignored field or method`, nil},
+		{"array append", "cfg.pair.append(1)", `has no .append field or method`, nil},
+		{"array index", "cfg.pair[2] = 1", `index 2 out of range`, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := bindConfig{Server: bindServer{Port: 1}}
+			err := execBound(t, c.script, &cfg)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.err)
+			if c.check != nil {
+				c.check(t, err)
+			}
+			// failed assignments leave the values unmodified
+			require.Equal(t, bindConfig{Server: bindServer{Port: 1}}, cfg)
+		})
+	}
+}
+
+func TestBind_ModifyDuringIteration(t *testing.T) {
+	cases := []struct {
+		name string
+		iter string
+		stmt string
+		err  string
+	}{
+		{"append", "cfg.server.tags", "cfg.server.tags.append(v)", `cannot append to []string during iteration`},
+		{"extend", "cfg.server.tags", "cfg.server.tags.extend([v])", `cannot append to []string during iteration`},
+		{"set index", "cfg.server.tags", "cfg.server.tags[0] = v + v", `cannot assign to element of []string during iteration`},
+		{"array set index", "cfg.pair", "cfg.pair[0] = 3", `cannot assign to element of [2]int during iteration`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := bindConfig{Server: bindServer{Tags: []string{"a", "b"}}, Pair: [2]int{1, 2}}
+			err := execBound(t, fmt.Sprintf(`
+def f():
+	for v in %s:
+		%s
+f()
+`, c.iter, c.stmt), &cfg)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.err)
+			require.Equal(t, []string{"a", "b"}, cfg.Server.Tags)
+			require.Equal(t, [2]int{1, 2}, cfg.Pair)
+		})
+	}
+
+	t.Run("read before iteration", func(t *testing.T) {
+		cfg := bindConfig{Server: bindServer{Tags: []string{"a", "b"}}}
+		err := execBound(t, `
+def f():
+	tags = cfg.server.tags
+	for v in cfg.server.tags:
+		for w in tags:
+			pass
+		tags.append(v)
+f()
+`, &cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `cannot append to []string during iteration`)
+		require.Equal(t, []string{"a", "b"}, cfg.Server.Tags)
+	})
+
+	t.Run("after iteration", func(t *testing.T) {
+		cfg := bindConfig{Server: bindServer{Tags: []string{"a", "b"}}}
+		err := execBound(t, `
+def f():
+	for v in cfg.server.tags:
+		pass
+	cfg.server.tags.append("c")
+	cfg.server.tags.extend(cfg.server.tags)
+	cfg.server.tags[0] = "x"
+f()
+`, &cfg)
+		require.NoError(t, err)
+		require.Equal(t, []string{"x", "b", "c", "a", "b", "c"}, cfg.Server.Tags)
+	})
+}
+
+func TestBind_Remain(t *testing.T) {
+	type Base struct {
+		Port int            `starlark:"port"`
+		Rest map[string]int `starlark:"rest,remain"`
+	}
+	var cfg struct {
+		Name string `starlark:"name"`
+		Base
+	}
+	cfg.Rest = map[string]int{"a": 1}
+
+	b := Bind(&cfg)
+	require.Equal(t, []string{"name", "port"}, b.(starlark.HasAttrs).AttrNames())
+
+	var th starlark.Thread
+	_, err := starlark.ExecFile(&th, "test", `x = cfg.rest`, starlark.StringDict{"cfg": b})
+	require.ErrorContains(t, err, `has no .rest field or method`)
+	_, err = starlark.ExecFile(&th, "test", `cfg.a = 2`, starlark.StringDict{"cfg": b})
+	require.ErrorContains(t, err, `has no .a field`)
+	require.Equal(t, map[string]int{"a": 1}, cfg.Rest)
+}
+
+func TestBind_Panics(t *testing.T) {
+	require.PanicsWithValue(t, "bound value is not a pointer to a struct: nil", func() {
+		Bind(nil)
+	})
+	require.PanicsWithValue(t, "bound value is not a pointer to a struct: starstruct.bindConfig", func() {
+		Bind(bindConfig{})
+	})
+	require.PanicsWithValue(t, "bound value is a nil pointer: *starstruct.bindConfig", func() {
+		Bind((*bindConfig)(nil))
+	})
+}
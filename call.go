@@ -0,0 +1,237 @@
+package starstruct
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"go.starlark.net/starlark"
+)
+
+// CallThread sets the function that returns the starlark thread to use to
+// call a starlark Callable decoded into a Go func by FromStarlark. It is
+// called for each call of the func, unless the first parameter of the func
+// is a *starlark.Thread, in which case that thread is used. To use the same
+// thread for all calls, return that thread, but note that a thread must not
+// be used concurrently. By default, a new thread named after the Go struct
+// path of the func is used for each call.
+func CallThread(fn func() *starlark.Thread) FromOption {
+	return func(d *decoder) {
+		d.thread = fn
+	}
+}
+
+// Call calls the starlark callable fn on the thread th with the Go arguments
+// args, which are converted following the rules of ToStarlark, and returns
+// its result converted to R following the rules of FromStarlark. A nil
+// argument is converted to None and a starlark.Value argument is passed
+// as-is.
+//
+// The conversions use the default rules, without options. Use CallWith and
+// a Caller to provide options, e.g. to convert the arguments with a naming
+// strategy.
+//
+// If the call fails, that error is returned. If an argument or the result
+// cannot be converted, the conversion errors are returned, identifying the
+// argument by its index (e.g. args[0]) or the result.
+func Call[R any](th *starlark.Thread, fn starlark.Value, args ...any) (R, error) {
+	return CallWith[R](&Caller{}, th, fn, args...)
+}
+
+// Caller holds the options of the conversions of the calls made with
+// CallWith. The zero value converts without options, as Call does.
+type Caller struct {
+	enc encoder
+	dec decoder
+}
+
+// NewCaller returns a Caller that converts the Go arguments of the calls with
+// the toOpts options, as for ToStarlark, and their result with the fromOpts
+// options, as for FromStarlark.
+func NewCaller(toOpts []ToOption, fromOpts []FromOption) *Caller {
+	var c Caller
+	for _, opt := range toOpts {
+		opt(&c.enc)
+	}
+	for _, opt := range fromOpts {
+		opt(&c.dec)
+	}
+	return &c
+}
+
+// CallWith calls the starlark callable fn on the thread th with the Go
+// arguments args and returns its result converted to R, as for Call, but
+// with the conversion options of the caller c.
+func CallWith[R any](c *Caller, th *starlark.Thread, fn starlark.Value, args ...any) (R, error) {
+	var res R
+
+	in := make([]reflect.Value, len(args))
+	for i := range args {
+		// use the interface value so that nil is supported
+		in[i] = reflect.ValueOf(&args[i]).Elem()
+	}
+
+	e := c.enc
+	sres, err := callStarlark(th, fn, in, &e)
+	if err != nil {
+		return res, err
+	}
+
+	d := c.dec
+	d.fromStarlarkValue("result", sres, reflect.ValueOf(&res).Elem(), nil)
+	if len(d.errs) > 0 {
+		return res, fmt.Errorf("%s: %w", callableName(fn), errors.Join(d.errs...))
+	}
+	return res, nil
+}
+
+// calls the starlark callable fn on the thread th with the Go arguments args,
+// converted with the encoder e.
+func callStarlark(th *starlark.Thread, fn starlark.Value, args []reflect.Value, e *encoder) (starlark.Value, error) {
+	sargs := make(starlark.Tuple, len(args))
+	for i, arg := range args {
+		sval := e.convertGoValue(fmt.Sprintf("args[%d]", i), arg, nil)
+		if sval == nil {
+			// unsupported value, skipped
+			sval = starlark.None
+		}
+		sargs[i] = sval
+	}
+	if len(e.errs) > 0 {
+		return nil, fmt.Errorf("%s: %w", callableName(fn), errors.Join(e.errs...))
+	}
+	return starlark.Call(th, fn, sargs, nil)
+}
+
+func callableName(fn starlark.Value) string {
+	if c, ok := fn.(starlark.Callable); ok {
+		return c.Name()
+	}
+	return fn.Type()
+}
+
+// starlarkFunc is a starlark callable called from Go as a func.
+type starlarkFunc struct {
+	path string
+	fn   starlark.Callable
+	typ  reflect.Type
+
+	// configuration of the decoder used to convert the results, and of the
+	// encoder used to convert the arguments.
+	dec decoder
+	enc encoder
+}
+
+// decodes the starlark value v, which must be None or a Callable, into the
+// func fld.
+func (d *decoder) setFieldFunc(path string, fld reflect.Value, v starlark.Value) {
+	if v == starlark.None {
+		fld.Set(reflect.Zero(fld.Type()))
+		return
+	}
+
+	fn, ok := v.(starlark.Callable)
+	if !ok {
+		d.recordTypeErr(path, v, fld)
+		return
+	}
+	sf := &starlarkFunc{
+		path: path,
+		fn:   fn,
+		typ:  fld.Type(),
+		dec:  d.config(),
+		enc:  encoder{unions: d.unions},
+	}
+	fld.Set(reflect.MakeFunc(fld.Type(), sf.call))
+}
+
+// calls the starlark callable with the arguments in of the Go func. If the
+// call fails or the arguments or results cannot be converted, the error is
+// returned as last result if it is an error, and it panics otherwise.
+func (f *starlarkFunc) call(in []reflect.Value) []reflect.Value {
+	var th *starlark.Thread
+	if len(in) > 0 && f.typ.In(0) == threadType {
+		th = in[0].Interface().(*starlark.Thread)
+		in = in[1:]
+	}
+	if th == nil {
+		if f.dec.thread != nil {
+			th = f.dec.thread()
+		} else {
+			th = &starlark.Thread{Name: f.path}
+		}
+	}
+
+	// pass the variadic arguments as distinct starlark arguments
+	if f.typ.IsVariadic() {
+		last := in[len(in)-1]
+		in = in[:len(in)-1]
+		for i := 0; i < last.Len(); i++ {
+			in = append(in, last.Index(i))
+		}
+	}
+
+	e := f.enc
+	sres, err := callStarlark(th, f.fn, in, &e)
+	var out []reflect.Value
+	if err == nil {
+		out, err = f.results(sres)
+	}
+
+	hasErr := f.typ.NumOut() > 0 && f.typ.Out(f.typ.NumOut()-1) == errorType
+	if err != nil {
+		if !hasErr {
+			panic(err)
+		}
+		out = make([]reflect.Value, f.typ.NumOut())
+		for i := range out {
+			out[i] = reflect.Zero(f.typ.Out(i))
+		}
+		out[len(out)-1] = reflect.ValueOf(&err).Elem()
+		return out
+	}
+	if hasErr {
+		out = append(out, reflect.Zero(errorType))
+	}
+	return out
+}
+
+// decodes the starlark result v of a call into the (non-error) results of
+// the Go func. If there are many results, v must be an Indexable value with
+// one element per result, such as a Tuple.
+func (f *starlarkFunc) results(v starlark.Value) ([]reflect.Value, error) {
+	n := f.typ.NumOut()
+	if n > 0 && f.typ.Out(n-1) == errorType {
+		n--
+	}
+
+	var vals []starlark.Value
+	switch n {
+	case 0:
+		return nil, nil
+	case 1:
+		vals = []starlark.Value{v}
+	default:
+		ix, ok := v.(starlark.Indexable)
+		if !ok {
+			return nil, fmt.Errorf("%s: got %s result, want a tuple of %d values", f.fn.Name(), v.Type(), n)
+		}
+		if ix.Len() != n {
+			return nil, fmt.Errorf("%s: got %d results, want %d", f.fn.Name(), ix.Len(), n)
+		}
+		for i := 0; i < n; i++ {
+			vals = append(vals, ix.Index(i))
+		}
+	}
+
+	d := f.dec
+	out := make([]reflect.Value, n)
+	for i, sval := range vals {
+		out[i] = reflect.New(f.typ.Out(i)).Elem()
+		d.fromStarlarkValue(fmt.Sprintf("results[%d]", i), sval, out[i], nil)
+	}
+	if len(d.errs) > 0 {
+		return nil, fmt.Errorf("%s: %w", f.fn.Name(), errors.Join(d.errs...))
+	}
+	return out, nil
+}
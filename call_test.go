@@ -0,0 +1,245 @@
+package starstruct
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.starlark.net/starlark"
+)
+
+func execScript(t *testing.T, script string, predeclared starlark.StringDict) starlark.StringDict {
+	t.Helper()
+	var th starlark.Thread
+	mod, err := starlark.ExecFile(&th, "test", script, predeclared)
+	require.NoError(t, err)
+	return mod
+}
+
+func TestCall(t *testing.T) {
+	mod := execScript(t, `
+def add(a, b):
+	return a + b
+
+def describe(srv):
+	return "%s:%d" % (srv["addr"], srv["port"])
+
+def pair():
+	return {"addr": "a", "port": 1}
+
+def is_none(v):
+	return v == None
+`, nil)
+
+	var th starlark.Thread
+	n, err := Call[int](&th, mod["add"], 1, 2)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	s, err := Call[string](&th, mod["describe"], struct {
+		Addr string `starlark:"addr"`
+		Port int    `starlark:"port"`
+	}{"localhost", 80})
+	require.NoError(t, err)
+	require.Equal(t, "localhost:80", s)
+
+	srv, err := Call[*bindServer](&th, mod["pair"])
+	require.NoError(t, err)
+	require.Equal(t, &bindServer{Addr: "a", Port: 1}, srv)
+
+	b, err := Call[bool](&th, mod["is_none"], nil)
+	require.NoError(t, err)
+	require.True(t, b)
+
+	v, err := Call[starlark.Value](&th, mod["add"], starlark.String("a"), "b")
+	require.NoError(t, err)
+	require.Equal(t, starlark.String("ab"), v)
+
+	// builtins can be called too
+	l, err := Call[int](&th, starlark.Universe["len"], []int{1, 2})
+	require.NoError(t, err)
+	require.Equal(t, 2, l)
+}
+
+func TestCall_Errors(t *testing.T) {
+	mod := execScript(t, `
+def add(a, b):
+	return a + b
+`, nil)
+
+	var th starlark.Thread
+	_, err := Call[int](&th, mod["add"], 1, "a")
+	require.Error(t, err)
+	var ee *starlark.EvalError
+	require.ErrorAs(t, err, &ee)
+	require.Contains(t, err.Error(), "unknown binary op: int + string")
+
+	_, err = Call[int](&th, mod["add"], 1, make(chan int))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "add: args[1]: unsupported Go type chan int")
+
+	_, err = Call[int](&th, mod["add"], "a", "b")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "add: result: cannot convert Starlark string to Go type int")
+	var te *TypeError
+	require.ErrorAs(t, err, &te)
+
+	_, err = Call[int](&th, starlark.MakeInt(1), 1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid call of non-function (int)")
+}
+
+func TestCallWith(t *testing.T) {
+	mod := execScript(t, `
+def describe(srv):
+	return "%s:%d" % (srv["srv_addr"], srv["srv_port"])
+
+def pair(addr, port):
+	return {"srv_addr": addr, "srv_port": port}
+`, nil)
+
+	type srv struct {
+		SrvAddr string
+		SrvPort int
+	}
+
+	var th starlark.Thread
+	c := NewCaller([]ToOption{FieldNamingTo(SnakeCaseNaming)}, []FromOption{FieldNamingFrom(SnakeCaseNaming)})
+	s, err := CallWith[string](c, &th, mod["describe"], srv{SrvAddr: "a", SrvPort: 1})
+	require.NoError(t, err)
+	require.Equal(t, "a:1", s)
+
+	res, err := CallWith[srv](c, &th, mod["pair"], "b", 2)
+	require.NoError(t, err)
+	require.Equal(t, srv{SrvAddr: "b", SrvPort: 2}, res)
+
+	// without options, the default rules apply
+	_, err = Call[string](&th, mod["describe"], srv{SrvAddr: "a", SrvPort: 1})
+	require.ErrorContains(t, err, "key \"srv_addr\" not in dict")
+	res, err = CallWith[srv](&Caller{}, &th, mod["pair"], "b", 2)
+	require.NoError(t, err)
+	require.Equal(t, srv{}, res)
+}
+
+type callRequest struct {
+	Path string `starlark:"path"`
+}
+
+type callResponse struct {
+	Status int    `starlark:"status"`
+	Body   string `starlark:"body"`
+}
+
+type callHooks struct {
+	OnRequest func(callRequest) (callResponse, error) `starlark:"on_request"`
+	OnStart   func()                                  `starlark:"on_start"`
+	Sum       func(...int) int                        `starlark:"sum"`
+	Split     func(string) (string, string, error)    `starlark:"split"`
+	Thread    func(*starlark.Thread) string           `starlark:"thread"`
+	NoErr     func(int) int                           `starlark:"no_err"`
+	Nil       func() error                            `starlark:"nil"`
+}
+
+func TestFromStarlark_Funcs(t *testing.T) {
+	var started int
+	mod := execScript(t, `
+def on_request(req):
+	if req["path"] == "/fail":
+		fail("boom")
+	if req["path"] == "/bad":
+		return {"status": "bad"}
+	return {"status": 200, "body": "got " + req["path"]}
+
+def on_start():
+	start()
+
+def sum(*vals):
+	total = 0
+	for v in vals:
+		total += v
+	return total
+
+def split(s):
+	if s == "none":
+		return None
+	if s == "one":
+		return (s,)
+	return s.split(":", 1)
+
+def thread():
+	return "thread"
+
+def no_err(i):
+	return str(i)
+
+nil = None
+`, starlark.StringDict{"start": Func(func() { started++ })})
+
+	var hooks callHooks
+	require.NoError(t, FromStarlark(mod, &hooks))
+	require.Nil(t, hooks.Nil)
+
+	resp, err := hooks.OnRequest(callRequest{Path: "/a"})
+	require.NoError(t, err)
+	require.Equal(t, callResponse{Status: 200, Body: "got /a"}, resp)
+
+	_, err = hooks.OnRequest(callRequest{Path: "/fail"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "boom")
+	var ee *starlark.EvalError
+	require.True(t, errors.As(err, &ee))
+
+	_, err = hooks.OnRequest(callRequest{Path: "/bad"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "on_request: results[0].Status: cannot convert Starlark string to Go type int")
+
+	hooks.OnStart()
+	require.Equal(t, 1, started)
+
+	require.Equal(t, 0, hooks.Sum())
+	require.Equal(t, 6, hooks.Sum(1, 2, 3))
+
+	a, b, err := hooks.Split("x:y:z")
+	require.NoError(t, err)
+	require.Equal(t, "x", a)
+	require.Equal(t, "y:z", b)
+
+	_, _, err = hooks.Split("one")
+	require.EqualError(t, err, "split: got 1 results, want 2")
+	_, _, err = hooks.Split("none")
+	require.EqualError(t, err, "split: got NoneType result, want a tuple of 2 values")
+
+	th := &starlark.Thread{Name: "explicit"}
+	require.Equal(t, "thread", hooks.Thread(th))
+
+	require.PanicsWithError(t, "no_err: results[0]: cannot convert Starlark string to Go type int", func() {
+		hooks.NoErr(1)
+	})
+}
+
+func TestFromStarlark_FuncsThread(t *testing.T) {
+	mod := execScript(t, `
+def name():
+	return "called"
+`, nil)
+
+	var th starlark.Thread
+	var calls int
+	var out struct {
+		Name func() (string, error) `starlark:"name"`
+		Bad  func()                 `starlark:"bad"`
+	}
+	err := FromStarlark(starlark.StringDict{"name": mod["name"], "bad": starlark.MakeInt(1)}, &out, CallThread(func() *starlark.Thread {
+		calls++
+		return &th
+	}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Bad: cannot convert Starlark int to Go type func()")
+
+	s, err := out.Name()
+	require.NoError(t, err)
+	require.Equal(t, "called", s)
+	_, err = out.Name()
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+}
@@ -0,0 +1,117 @@
+package starstruct
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Collector collects records of type T, which must be a struct or a pointer
+// to a struct, declared by a starlark script through calls to a builtin, in
+// the style of Bazel rules, e.g.:
+//
+//	service(name = "api", port = 8080)
+//	service(name = "web", port = 80)
+//
+// Each call creates a new T and unpacks its arguments into it as for
+// UnpackArgs (so that unknown keyword arguments are rejected), and the
+// record is appended to the collector's records. A Collector is safe for
+// concurrent use by multiple starlark threads.
+type Collector[T any] struct {
+	name   string
+	dec    decoder
+	unique func(T) string
+
+	mu    sync.Mutex
+	items []T
+	seen  map[string]syntax.Position
+}
+
+// NewCollector returns a Collector that exposes a builtin named name. The
+// arguments of the calls are decoded with the provided options.
+//
+// It panics if T is not a struct or a pointer to a struct, or if the struct
+// tag options of its fields are invalid.
+func NewCollector[T any](name string, opts ...FromOption) *Collector[T] {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if !isStructOrPtrType(typ) {
+		panic(fmt.Sprintf("type is not a struct or a pointer to a struct: %s", typ))
+	}
+	mustCheckTags(typ)
+
+	c := &Collector[T]{name: name}
+	for _, opt := range opts {
+		opt(&c.dec)
+	}
+	return c
+}
+
+// UniqueBy configures the collector to require that the key returned by fn
+// for each record is unique, e.g. the name of a service. A call that
+// declares a record with the same key as a previous one fails with an error
+// that mentions the position of that previous call (if it was called from a
+// script). It returns the collector so that it can be chained with
+// NewCollector.
+func (c *Collector[T]) UniqueBy(fn func(T) string) *Collector[T] {
+	c.unique = fn
+	return c
+}
+
+// Builtin returns the starlark builtin that collects a record on each call.
+// It returns None. If the arguments cannot be unpacked into the record or
+// the record is not unique, the call fails with the error of the conversion
+// (which mentions the Go struct path of the field in error) or of the
+// uniqueness check. As for any builtin, the position of the call in the
+// script is reported by the starlark.EvalError's call stack.
+func (c *Collector[T]) Builtin() *starlark.Builtin {
+	return starlark.NewBuiltin(c.name, c.collect)
+}
+
+// Items returns a copy of the records collected so far, in order of the
+// calls.
+func (c *Collector[T]) Items() []T {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]T(nil), c.items...)
+}
+
+func (c *Collector[T]) collect(th *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	// the position of the caller, if called from a script (depth 0 is the
+	// builtin itself)
+	var pos syntax.Position
+	if th.CallStackDepth() > 1 {
+		pos = th.CallFrame(1).Pos
+	}
+
+	var item T
+	strct := reflect.ValueOf(&item).Elem()
+	if strct.Kind() == reflect.Pointer {
+		strct.Set(reflect.New(strct.Type().Elem()))
+		strct = strct.Elem()
+	}
+	if err := c.dec.unpackArgs(b.Name(), args, kwargs, strct); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.unique != nil {
+		key := c.unique(item)
+		if prev, ok := c.seen[key]; ok {
+			if !prev.IsValid() {
+				return nil, fmt.Errorf("%s: duplicate key %q", b.Name(), key)
+			}
+			return nil, fmt.Errorf("%s: duplicate key %q, previously declared at %s", b.Name(), key, prev)
+		}
+		if c.seen == nil {
+			c.seen = make(map[string]syntax.Position)
+		}
+		c.seen[key] = pos
+	}
+	c.items = append(c.items, item)
+	return starlark.None, nil
+}
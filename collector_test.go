@@ -0,0 +1,109 @@
+package starstruct
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.starlark.net/starlark"
+)
+
+type collService struct {
+	Name  string            `starlark:"name,required"`
+	Port  int               `starlark:"port"`
+	Deps  []string          `starlark:"deps"`
+	Env   map[string]string `starlark:"env"`
+	Extra any               `starlark:"extra"`
+}
+
+func execCollector(t *testing.T, script string, b *starlark.Builtin) error {
+	t.Helper()
+	var th starlark.Thread
+	_, err := starlark.ExecFile(&th, "test", script, starlark.StringDict{b.Name(): b})
+	return err
+}
+
+func TestCollector(t *testing.T) {
+	const script = `
+service(name = "api", port = 8080, deps = ["db"])
+for i in range(2):
+	service(name = "worker-%d" % i, env = {"ID": str(i)})
+`
+
+	c := NewCollector[collService]("service").UniqueBy(func(s collService) string { return s.Name })
+	require.NoError(t, execCollector(t, script, c.Builtin()))
+	require.Equal(t, []collService{
+		{Name: "api", Port: 8080, Deps: []string{"db"}},
+		{Name: "worker-0", Env: map[string]string{"ID": "0"}},
+		{Name: "worker-1", Env: map[string]string{"ID": "1"}},
+	}, c.Items())
+
+	// the returned records are a copy
+	items := c.Items()
+	items[0].Name = "x"
+	require.Equal(t, "api", c.Items()[0].Name)
+
+	t.Run("pointers", func(t *testing.T) {
+		c := NewCollector[*collService]("service", AnySetType(reflect.TypeOf(map[any]bool{})))
+		require.NoError(t, execCollector(t, `service(name = "a", extra = set([1]))`, c.Builtin()))
+		require.Equal(t, []*collService{{Name: "a", Extra: map[any]bool{int64(1): true}}}, c.Items())
+	})
+}
+
+func TestCollector_Errors(t *testing.T) {
+	cases := []struct {
+		name   string
+		script string
+		err    string
+		pos    string
+	}{
+		{"duplicate", `
+service(name = "a")
+service(name = "b")
+service(name = "a")`, `service: duplicate key "a", previously declared at test:2:8`, "test:4:8"},
+		{"field path", `
+service(name = "a", env = {"x": 1})`, `service: for parameter env: Env["x"]: cannot convert Starlark int to Go type string`, "test:2:8"},
+		{"unknown keyword", `
+service(name = "a", prot = 1)`, `service: unexpected keyword argument "prot"`, "test:2:8"},
+		{"missing", `
+service(port = 1)`, `service: missing argument for name`, "test:2:8"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			coll := NewCollector[collService]("service").UniqueBy(func(s collService) string { return s.Name })
+			err := execCollector(t, c.script, coll.Builtin())
+			var ee *starlark.EvalError
+			require.ErrorAs(t, err, &ee)
+			require.Equal(t, c.err, ee.Msg)
+			require.Equal(t, c.pos, ee.CallStack.At(1).Pos.String())
+		})
+	}
+
+	t.Run("called from Go", func(t *testing.T) {
+		coll := NewCollector[collService]("service").UniqueBy(func(s collService) string { return s.Name })
+		b := coll.Builtin()
+		kwargs := []starlark.Tuple{{starlark.String("name"), starlark.String("a")}}
+		var th starlark.Thread
+		_, err := starlark.Call(&th, b, nil, kwargs)
+		require.NoError(t, err)
+		_, err = starlark.Call(&th, b, nil, kwargs)
+		require.EqualError(t, err, `service: duplicate key "a"`)
+		_, err = starlark.Call(&th, b, nil, nil)
+		require.EqualError(t, err, `service: missing argument for name`)
+		require.Len(t, coll.Items(), 1)
+	})
+
+	t.Run("error types", func(t *testing.T) {
+		coll := NewCollector[collService]("service")
+		err := execCollector(t, `service(name = 1)`, coll.Builtin())
+		var te *TypeError
+		require.ErrorAs(t, err, &te)
+		require.Equal(t, "Name", te.Path)
+		require.Len(t, coll.Items(), 0)
+	})
+
+	require.PanicsWithValue(t, "type is not a struct or a pointer to a struct: int", func() {
+		NewCollector[int]("x")
+	})
+}
@@ -0,0 +1,85 @@
+package starstruct
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.starlark.net/starlark"
+)
+
+// Constructor returns a starlark builtin that creates values of the struct
+// type typ (which may be a pointer to a struct), e.g. a Server builtin that
+// can be called in a script as Server(addr = "x", ports = [80]). The builtin
+// is named after the type.
+//
+// The arguments of the call are unpacked into a new zero value of the struct
+// as for UnpackArgs with the provided options, so that unknown keyword
+// arguments, missing required arguments and arguments that cannot be
+// converted to the type of their field fail the call, at its position in the
+// script. The new value is returned as a bound value, as for Bind with the
+// same options, so that its fields can be read and assigned with the same
+// naming and validation. Unlike the values returned by Bind, it can be frozen
+// (e.g. as a global of a module once it is executed), after which its fields
+// and the nested bound values cannot be modified anymore.
+//
+// FromStarlark decodes such a value (and any other value returned by Bind)
+// into a field of the same struct type, a pointer to that type or an
+// interface implemented by that type as a copy of the Go value, as with an
+// assignment in Go (that is, the copy shares the slices, maps and pointers of
+// the original). In other fields, it is decoded as any other value with
+// attributes.
+//
+// It panics if typ is not a struct or a pointer to a struct, or if the struct
+// tag options of its fields are invalid.
+func Constructor(typ reflect.Type, opts ...FromOption) *starlark.Builtin {
+	strctTyp := typ
+	if strctTyp.Kind() == reflect.Pointer {
+		strctTyp = strctTyp.Elem()
+	}
+	if strctTyp.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("type is not a struct or a pointer to a struct: %s", typ))
+	}
+	mustCheckTags(strctTyp)
+
+	var d decoder
+	for _, opt := range opts {
+		opt(&d)
+	}
+
+	name := strctTyp.Name()
+	if name == "" {
+		name = strctTyp.String()
+	}
+	return starlark.NewBuiltin(name, func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		ptr := reflect.New(strctTyp)
+		dec := d.config()
+		if err := dec.unpackArgs(b.Name(), args, kwargs, ptr.Elem()); err != nil {
+			return nil, err
+		}
+		return &boundStruct{v: ptr.Elem(), bnd: &binding{dec: d.config(), canFreeze: true}}, nil
+	})
+}
+
+// sets fld to a copy of the Go struct of the bound value b if fld is of the
+// same type, a pointer to that type or an interface implemented by that type.
+// It returns false if fld is not such a type.
+func (d *decoder) setFieldBound(fld reflect.Value, b *boundStruct) bool {
+	typ := fld.Type()
+	switch {
+	case typ == b.v.Type():
+		fld.Set(b.v)
+	case typ.Kind() == reflect.Pointer && typ.Elem() == b.v.Type():
+		ptr := reflect.New(b.v.Type())
+		ptr.Elem().Set(b.v)
+		fld.Set(ptr)
+	case typ.Kind() == reflect.Interface && b.v.Type().Implements(typ):
+		fld.Set(b.v)
+	case typ.Kind() == reflect.Interface && reflect.PointerTo(b.v.Type()).Implements(typ):
+		ptr := reflect.New(b.v.Type())
+		ptr.Elem().Set(b.v)
+		fld.Set(ptr)
+	default:
+		return false
+	}
+	return true
+}
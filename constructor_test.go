@@ -0,0 +1,122 @@
+package starstruct
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.starlark.net/starlark"
+)
+
+type ctorServer struct {
+	Addr   string         `starlark:"addr,required"`
+	Ports  []int          `starlark:"ports"`
+	Tags   []string       `starlark:"tags"`
+	Limits map[string]int `starlark:"limits"`
+}
+
+func (s ctorServer) String() string { return fmt.Sprintf("%s%v", s.Addr, s.Ports) }
+
+func TestConstructor(t *testing.T) {
+	const script = `
+srv = Server(addr = "x", ports = [80])
+srv.tags = ["a"]
+other = Server("y")
+servers = [srv, other]
+first = srv
+port = srv.ports[0]
+`
+
+	ctor := Constructor(reflect.TypeOf(&ctorServer{}))
+	require.Equal(t, "ctorServer", ctor.Name())
+
+	var th starlark.Thread
+	mod, err := starlark.ExecFile(&th, "test", script, starlark.StringDict{"Server": ctor})
+	require.NoError(t, err)
+	require.Equal(t, "starstruct.ctorServer", mod["srv"].Type())
+	require.Equal(t, starlark.MakeInt(80), mod["port"])
+
+	var out struct {
+		First   ctorServer     `starlark:"first"`
+		Other   *ctorServer    `starlark:"other"`
+		Servers []fmt.Stringer `starlark:"servers"`
+		Any     any            `starlark:"srv"`
+	}
+	require.NoError(t, FromStarlark(mod, &out))
+	srv := ctorServer{Addr: "x", Ports: []int{80}, Tags: []string{"a"}}
+	require.Equal(t, srv, out.First)
+	require.Equal(t, &ctorServer{Addr: "y"}, out.Other)
+	require.Equal(t, []fmt.Stringer{srv, ctorServer{Addr: "y"}}, out.Servers)
+	require.Equal(t, srv, out.Any)
+
+	// a value of another struct type is decoded from its attributes
+	var other struct {
+		Srv struct {
+			Addr string `starlark:"addr"`
+		} `starlark:"srv"`
+	}
+	require.NoError(t, FromStarlark(mod, &other))
+	require.Equal(t, "x", other.Srv.Addr)
+}
+
+func TestConstructor_Frozen(t *testing.T) {
+	ctor := Constructor(reflect.TypeOf(ctorServer{}))
+	var th starlark.Thread
+	globals, err := starlark.ExecFile(&th, "base", `srv = Server(addr = "x", ports = [80])`, starlark.StringDict{"Server": ctor})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{"field", `srv.addr = "y"`, `cannot assign to field of frozen starstruct.ctorServer`},
+		{"key", `srv["addr"] = "y"`, `cannot assign to field of frozen starstruct.ctorServer`},
+		{"slice index", `srv.ports[0] = 1`, `cannot assign to element of frozen []int`},
+		{"slice append", `srv.ports.append(1)`, `cannot append to frozen []int`},
+		{"slice extend", `srv.ports.extend([1])`, `cannot append to frozen []int`},
+		{"map key", `srv.limits["a"] = 1`, `cannot insert into frozen map[string]int`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := starlark.ExecFile(&th, "test", c.script, starlark.StringDict{"srv": globals["srv"]})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.err)
+		})
+	}
+
+	var out struct {
+		Srv ctorServer `starlark:"srv"`
+	}
+	require.NoError(t, FromStarlark(globals, &out))
+	require.Equal(t, ctorServer{Addr: "x", Ports: []int{80}}, out.Srv)
+}
+
+func TestConstructor_Errors(t *testing.T) {
+	cases := []struct {
+		script string
+		err    string
+	}{
+		{`Server(addr = "x", prots = [80])`, `ctorServer: unexpected keyword argument "prots"`},
+		{`Server(addr = 1)`, `ctorServer: for parameter addr: Addr: cannot convert Starlark int to Go type string`},
+		{`Server(ports = [80])`, `ctorServer: missing argument for addr`},
+		{`Server("x").ports = ["a"]`, `Ports[0]: cannot convert Starlark string to Go type int`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.script, func(t *testing.T) {
+			var th starlark.Thread
+			_, err := starlark.ExecFile(&th, "test", "\n"+c.script, starlark.StringDict{"Server": Constructor(reflect.TypeOf(ctorServer{}))})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.err)
+			var ee *starlark.EvalError
+			require.ErrorAs(t, err, &ee)
+			require.Contains(t, ee.Backtrace(), "test:2:")
+		})
+	}
+
+	require.PanicsWithValue(t, "type is not a struct or a pointer to a struct: string", func() {
+		Constructor(reflect.TypeOf(""))
+	})
+}
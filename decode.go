@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
 	"reflect"
 	"strings"
 
@@ -43,40 +44,212 @@ func CustomFromConverter(fn func(path string, starVal starlark.Value, goVal refl
 	}
 }
 
+// AnySetType sets the Go type used to decode a starlark Set into an interface
+// value (such as an any field). It must be a type that a Set can be decoded
+// into, e.g. []any, []string or map[string]bool. The default is []any.
+func AnySetType(t reflect.Type) FromOption {
+	return func(d *decoder) {
+		d.anySetType = t
+	}
+}
+
+// AnyBigIntType sets the Go type used to decode a starlark Int that does not
+// fit in an int64 into an interface value (such as an any field). It must be
+// a type that an Int can be decoded into, e.g. uint64 or float64 (in which
+// case a NumberError is reported if the value cannot be represented). The
+// default is *big.Int.
+func AnyBigIntType(t reflect.Type) FromOption {
+	return func(d *decoder) {
+		d.anyBigIntType = t
+	}
+}
+
 // FromStarlark loads the starlark values from vals into a destination Go
 // struct. It supports the following data types from Starlark to Go, and all Go
 // types can also be a pointer to that type:
 //   - NoneType => nil (Go field must be a pointer, slice or map)
 //   - Bool     => bool
-//   - Bytes    => []byte or string
-//   - String   => []byte or string
-//   - Float    => float32 or float64
-//   - Int      => int, uint, and any sized (u)int if it fits
-//   - Dict     => struct
-//   - List     => slice of any supported Go type
-//   - Tuple    => slice of any supported Go type
-//   - Set      => map[T]bool or []T where T is any supported Go type
+//   - Bytes    => []byte, [N]byte or string
+//   - String   => []byte, [N]byte or string
+//   - Float    => float32, float64, big.Float or big.Rat, and big.Int if
+//     it has no fractional part
+//   - Int      => int, uint, and any sized (u)int if it fits, big.Int,
+//     big.Float or big.Rat
+//   - Time     => time.Time (lib/time Time, from go.starlark.net/lib/time)
+//   - Duration => time.Duration (lib/time Duration)
+//   - Dict     => struct, or map[K]T where K is any supported key type (see
+//     below) and T is any supported Go type
+//   - HasAttrs => struct (e.g. a starlarkstruct.Struct or Module, decoded
+//     as if it was a Dict of its attributes)
+//   - List     => slice or array of any supported Go type
+//   - Tuple    => slice or array of any supported Go type
+//   - Set      => map[T]bool, []T or [N]T where T is any supported Go type
+//   - IterableMapping => struct or map, as for a Dict (e.g. a custom mapping
+//     type)
+//   - Iterable => slice or array of any supported Go type, as for a List
+//     (e.g. the result of range or a custom container type)
+//   - Callable => func, that calls the starlark callable (see below)
+//
+// A time.Time can also be decoded from an RFC 3339 String or from an Int
+// number of seconds since the Unix epoch, and a time.Duration from a String
+// such as "5m" (as accepted by time.ParseDuration) or from an Int number of
+// nanoseconds. The unit of the Int can be set with the same struct tag option
+// as for ToStarlark, e.g. `starlark:"name,asint=ms"` (an invalid unit is
+// recorded as a TagError). A String that cannot be parsed results in a
+// ParseError.
+//
+// If the Go type implements Unmarshaler (with a pointer receiver), the
+// starlark value is decoded by calling its UnmarshalStarlark method, which
+// takes precedence over all other conversion rules (except that None decoded
+// into a pointer sets it to nil). An error returned by the method is recorded
+// as a CustomConvError.
+//
+// If the Go type implements encoding.TextUnmarshaler or
+// encoding.BinaryUnmarshaler (with a pointer receiver), a String or Bytes is
+// decoded by calling UnmarshalText or UnmarshalBinary (UnmarshalText is
+// preferred for a String and UnmarshalBinary for Bytes). An error returned by
+// the unmarshaler is recorded as a MarshalerError and the Go value is left
+// unmodified. This can be disabled with the IgnoreTextUnmarshalers option.
+//
+// A Callable (e.g. a function defined in the script) decoded into a Go func
+// returns a func that converts its arguments following the rules of
+// ToStarlark, calls the starlark callable and converts its result following
+// the rules of FromStarlark (see also Call). If the func has many results
+// (other than an error), the callable must return a Tuple with one value per
+// result. If the last result of the func is an error, the error of the call
+// or of the conversions is returned there, otherwise the func panics with
+// that error. The thread used for the call can be set with the CallThread
+// option, or provided as the first parameter of the func if it is a
+// *starlark.Thread.
+//
+// A field with the required struct tag option, e.g.
+// `starlark:"name,required"`, that has no matching key in the starlark values
+// results in a MissingFieldError. A field with the default struct tag option
+// is set to that default value when it has no matching key, the value being a
+// starlark literal decoded as if it was provided for that key, e.g.
+// `starlark:"port,default=80"` or `starlark:"tags,default=['a', 'b']"` (it
+// is a literal number, string, bytes, None, True, False, or a list, tuple or
+// dict of literals). As it may contain commas, the default option extends to
+// the regexp option or the end of the struct tag options, so it must be the
+// last option, or be followed only by the regexp option, e.g.
+// `starlark:"code,default='ab',regexp=^[a-z]{1,3}$"`. A field with a default
+// value is never missing, and with the NoneAsDefault option, None resets it
+// to its default value. A default value that is not a valid literal is
+// recorded as a TagError.
+//
+// The starlark key of a field is the name in its starlark struct tag or, if
+// there is none, the name of the field, or that name in lowercase if there is
+// no key with that name. A NamingStrategy can be set with the FieldNamingFrom
+// option, in which case only the key that it returns is used (e.g. max_conns
+// for a MaxConns field with SnakeCaseNaming). With the CaseInsensitiveKeys
+// option, the keys match the fields regardless of case, and an
+// AmbiguousKeyError is reported if more than one key matches a field.
+//
+// By default, the keys of the starlark values that do not match any field of
+// the destination struct are ignored, as are the keys that are not strings.
+// With the DisallowUnknownKeys option, they are reported as UnknownKeyError
+// errors, which suggest the closest field names.
+//
+// A field with the remain struct tag option, e.g. `starlark:",remain"`,
+// receives the keys of the starlark values that do not match any other field
+// of the struct (including the fields of its embedded structs), so that they
+// can be kept instead of being ignored. Its type must be a map with string
+// keys, such as map[string]starlark.Value or map[string]any, and it is
+// decoded as for a Dict of those keys (the keys that are not strings are not
+// included). A struct can have at most one remain field, either directly or
+// in an embedded struct (not a pointer to a struct), otherwise a TagError is
+// recorded.
+//
+// Validation constraints can be set in the struct tag options of a field, in
+// addition to the conversion options, e.g. `starlark:"port,min=1,max=65535"`.
+// A field decoded without error is validated against its constraints, and a
+// ValidationError is recorded for each constraint that it does not satisfy:
+//   - min=N and max=N: the number must be at least or at most N (for a
+//     time.Duration, N is a duration such as 1s)
+//   - len=N, minlen=N and maxlen=N: the length of the string (in runes),
+//     slice, array or map must be exactly, at least or at most N
+//   - oneof=a|b|c: the string, bool or number must be one of the values
+//     separated by "|"
+//   - regexp=expr: the string must match the regular expression, which may
+//     contain commas as it extends to the default option or the end of the
+//     struct tag options (so it must be the last option, or be followed only
+//     by the default option)
+//   - nonempty: the string, slice, array or map must not be empty, and any
+//     other value must not be the zero value
+//
+// A nil pointer only fails the nonempty constraint, the other constraints
+// apply to the value it points to. A constraint that is invalid or that does
+// not apply to the type of the field is recorded as a TagError.
+//
+// Once a struct is decoded without error, its AfterDecode and Validate
+// methods are called if it implements the AfterDecoder and Validator
+// interfaces (in that order, Validate being skipped if AfterDecode fails).
+// An error returned by those methods is recorded as a HookError. The
+// top-level struct dst and the nested structs are supported, but not the
+// embedded structs, as their methods are promoted to the parent struct.
+//
+// A value created by a Constructor or returned by Bind is copied as-is into
+// a field of the same Go type, as described for Constructor.
 //
 // In addition to those conversions, if the Go type is starlark.Value (or a
 // pointer to that type), then the starlark value is assigned as-is.
 //
+// If the Go type is any other interface (or a pointer to an interface), such
+// as any, the starlark value is decoded into its natural Go type, provided
+// that this type implements the interface:
+//   - NoneType => nil
+//   - Bool     => bool
+//   - Bytes    => []byte
+//   - String   => string
+//   - Float    => float64
+//   - Int      => int64, or *big.Int if it does not fit (see AnyBigIntType)
+//   - Dict     => map[string]any
+//   - List     => []any
+//   - Tuple    => []any
+//   - Set      => []any (see AnySetType)
+//   - IterableMapping => map[string]any
+//   - Iterable => []any
+//
+// A Dict can also be decoded into an interface registered as a discriminated
+// union, in which case the concrete Go type is identified by the value of the
+// discriminator key (see UnionRegistry and UnionFromRegistry).
+//
 // Additional conversions can be supported via a custom converter (see
 // CustomFromConverter).
 //
 // It panics if dst is not a non-nil pointer to an addressable and settable
-// struct. If a target Go field does have a matching key in the starlark
-// dictionary, it is unmodified.
+// struct. If a target Go field does not have a matching key in the starlark
+// dictionary (nor a default value), it is unmodified.
 //
 // Decoding into a slice follows the same behavior as JSON umarshaling: it
 // resets the slice length to zero and then appends each element to the slice.
 // As a special case, to decode an empty starlark List, Tuple or Set into a
 // slice, it replaces the slice with a new empty slice.
 //
+// Decoding into an array requires the starlark value to have exactly the same
+// length as the array, otherwise a LengthError is reported and the array is
+// left unmodified. Each element of the array is replaced by the decoded
+// starlark value, but only if all elements are decoded without error (the
+// array is otherwise left unmodified, and a nil pointer to an array is left
+// nil).
+//
 // Decoding a Set into a map also follows the same behavior as JSON
 // unmarshaling: if the map is nil, it allocates a new map. Otherwise it reuses
 // the existing map, keeping existing entries. It then stores each Set key with
 // a true value into the map.
 //
+// Decoding a Dict into a map behaves the same way: if the map is nil, it
+// allocates a new map, otherwise it reuses the existing map, keeping existing
+// entries. It then stores each key-value pair of the Dict into the map, the
+// value being decoded into a new zero value of the map's element type. An
+// entry whose key or value fails to convert is not stored in the map.
+//
+// The supported Go map key types are bool, string, float and (u)int types,
+// as well as arrays and structs (with only exported fields) of those types,
+// recursively. Arrays and structs are decoded from a Tuple, positionally -
+// that is, a Go map[[2]string]T or map[struct{ Port int; Proto string }]T can
+// be decoded from a Dict with keys such as ("a", "b") and (80, "tcp").
+//
 // Embedded fields in structs are supported as follows:
 //   - The field must be exported
 //   - The type of the field must be a struct or a pointer to a struct
@@ -113,9 +286,22 @@ func FromStarlark(vals starlark.StringDict, dst any, opts ...FromOption) error {
 }
 
 type decoder struct {
-	errs    []error
-	maxErrs int
-	custom  func(string, starlark.Value, reflect.Value) (bool, error)
+	errs          []error
+	maxErrs       int
+	custom        func(string, starlark.Value, reflect.Value) (bool, error)
+	anySetType    reflect.Type
+	anyBigIntType reflect.Type
+	thread        func() *starlark.Thread
+	unions        *UnionRegistry
+
+	ignoreUnmarshalers bool
+	noneAsDefault      bool
+
+	strict        bool
+	allowGlobal   func(string, starlark.Value) bool
+	discriminator string
+	naming        NamingStrategy
+	foldCase      bool
 }
 
 func (d *decoder) decode(strct reflect.Value, sdict starlark.StringDict) (err error) {
@@ -129,18 +315,21 @@ func (d *decoder) decode(strct reflect.Value, sdict starlark.StringDict) (err er
 		}
 	}()
 
-	d.setFieldDict("", strct, false, stringDictValue{sdict})
+	d.setFieldDict("", strct, false, stringDictValue{sdict}, nil)
 	err = errors.Join(d.errs...)
 	return
 }
 
 func (d *decoder) walkStructDecode(path string, strct reflect.Value, vals dictGetSetter) (didSet bool) {
+	var idx foldIndex // built on first use, if the keys are case-insensitive
+
 	strctTyp := strct.Type()
 	count := strctTyp.NumField()
 	for i := 0; i < count; i++ {
 		fldTyp := strctTyp.Field(i)
-		nm, _, _ := strings.Cut(fldTyp.Tag.Get("starlark"), ",")
-		if !fldTyp.IsExported() || nm == "-" {
+		nm, rawOpts, _ := strings.Cut(fldTyp.Tag.Get("starlark"), ",")
+		if !fldTyp.IsExported() || nm == "-" || isRemainTag(rawOpts) {
+			// the remain field is decoded once all fields are
 			continue
 		}
 
@@ -154,40 +343,66 @@ func (d *decoder) walkStructDecode(path string, strct reflect.Value, vals dictGe
 		fld := strct.Field(i)
 
 		var tryLower bool
-		// use the field name as default lookup value, except if the field is an
-		// embedded anonymous struct - in this case we will walk this embedded
-		// struct with the current vals.
+		// use the field name (or its key according to the naming strategy) as
+		// default lookup value, except if the field is an embedded anonymous
+		// struct - in this case we will walk this embedded struct with the
+		// current vals.
 		if nm == "" {
 			if fldTyp.Anonymous {
-				if ok := d.setFieldDict(path, fld, true, vals); ok {
+				if ok := d.setFieldDict(path, fld, true, vals, nil); ok {
 					didSet = true
 				}
 				continue
 			}
-			nm = fldTyp.Name
-			tryLower = true // if no match is found with the field name, try all lowercase
+			// if no match is found with the field name, try all lowercase, unless
+			// a naming strategy is set.
+			nm, tryLower = fieldKey(d.naming, fldTyp.Name)
 		}
 
-		matchingVal, ok, _ := vals.Get(starlark.String(nm)) // cannot fail, key is a string
-		if !ok {
-			if tryLower {
-				matchingVal, ok, _ = vals.Get(starlark.String(strings.ToLower(nm)))
+		var matchingVal starlark.Value
+		var ok bool
+		if d.foldCase {
+			if idx == nil {
+				idx = newFoldIndex(vals)
 			}
-			if !ok {
-				// leave the field unmodified, no matching starlark value
+			keys := idx.lookup(nm)
+			if len(keys) > 1 {
+				d.recordAmbiguousKeyErr(path, keys)
 				continue
 			}
+			if len(keys) == 1 {
+				matchingVal, ok, _ = vals.Get(keys[0])
+			}
+		} else {
+			matchingVal, ok, _ = vals.Get(starlark.String(nm)) // cannot fail, key is a string
+			if !ok && tryLower {
+				matchingVal, ok, _ = vals.Get(starlark.String(strings.ToLower(nm)))
+			}
+		}
+
+		opts, fopts := parseTagOpts(rawOpts)
+		matchingVal, ok = d.withDefault(path, matchingVal, ok, fopts)
+		if !ok {
+			// leave the field unmodified, no matching starlark value nor default
+			if _, req := findFieldOpt(fopts, "required"); req {
+				d.recordMissingFieldErr(path, nm)
+			}
+			continue
 		}
 
 		// at this point, the struct field has a matching starlark value, so it
 		// will either set it or return an error.
 		didSet = true
-		d.fromStarlarkValue(path, matchingVal, fld)
+		nerrs := len(d.errs)
+		d.fromStarlarkValue(path, matchingVal, fld, opts)
+		if len(d.errs) == nerrs {
+			d.validateField(path, fld, fopts)
+		}
 	}
 	return didSet
 }
 
-func (d *decoder) fromStarlarkValue(path string, starVal starlark.Value, dst reflect.Value) {
+func (d *decoder) fromStarlarkValue(path string, starVal starlark.Value, dst reflect.Value, opts tagOpt) {
 	if fn := d.custom; fn != nil {
 		ok, err := fn(path, starVal, dst)
 		if err != nil {
@@ -199,12 +414,55 @@ func (d *decoder) fromStarlarkValue(path string, starVal starlark.Value, dst ref
 		}
 	}
 
+	// bound Go values are copied as-is in a field of the same type.
+	if b, ok := starVal.(*boundStruct); ok && d.setFieldBound(dst, b) {
+		return
+	}
+
+	// types that implement Unmarshaler decode themselves, except for None into
+	// a pointer, which is set to nil.
+	if t := dst.Type(); isStarlarkUnmarshalerType(t) && (starVal != starlark.None || t.Kind() != reflect.Pointer) {
+		d.setFieldStarlarkUnmarshaler(path, dst, starVal)
+		return
+	}
+
 	// if destination is starlark.Value interface (or a pointer to it), assign
 	// it directly, as-is.
 	if t := dst.Type(); isTOrPtrTType(t, starlarkValueType) {
 		d.setFieldStarlark(path, dst, starVal)
 		return
 	}
+	// arbitrary-precision numbers are structs, but are decoded from numbers.
+	if t := dst.Type(); starVal != starlark.None && isTOrPtrTBigNumType(t) {
+		d.setFieldBigNum(path, dst, starVal)
+		return
+	}
+	// time values are structs or integers, but are decoded from lib/time values,
+	// strings or integers.
+	if t := dst.Type(); starVal != starlark.None && isTOrPtrTTimeType(t) {
+		d.setFieldTime(path, dst, starVal, opts.current())
+		return
+	}
+	// if destination is any other interface (or a pointer to it), decode into
+	// the natural Go type of the starlark value.
+	if t := dst.Type(); t.Kind() == reflect.Interface || t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Interface {
+		d.setFieldAny(path, dst, starVal, opts)
+		return
+	}
+	// types that implement encoding.TextUnmarshaler or
+	// encoding.BinaryUnmarshaler are decoded from strings or bytes by calling
+	// the unmarshaler.
+	if t := dst.Type(); !d.ignoreUnmarshalers && isStringOrBytes(starVal) && isUnmarshalerType(t) {
+		d.setFieldUnmarshaler(path, dst, starVal)
+		return
+	}
+
+	// funcs are decoded from callables, and called with the converted Go
+	// arguments.
+	if t := dst.Type(); t.Kind() == reflect.Func {
+		d.setFieldFunc(path, dst, starVal)
+		return
+	}
 
 	switch v := starVal.(type) {
 	case starlark.NoneType:
@@ -220,13 +478,22 @@ func (d *decoder) fromStarlarkValue(path string, starVal starlark.Value, dst ref
 	case starlark.Float:
 		d.setFieldFloat(path, dst, v)
 	case *starlark.Dict:
-		d.setFieldDict(path, dst, false, v)
+		d.setFieldDict(path, dst, false, v, opts)
 	case *starlark.List:
-		d.setFieldList(path, dst, v)
+		d.setFieldList(path, dst, v, opts)
 	case starlark.Tuple:
-		d.setFieldTuple(path, dst, v)
+		d.setFieldTuple(path, dst, v, opts)
 	case *starlark.Set:
-		d.setFieldSet(path, dst, v)
+		d.setFieldSet(path, dst, v, opts)
+	case starlark.IterableMapping:
+		// e.g. a custom mapping type
+		d.setFieldDict(path, dst, false, mappingValue{v}, opts)
+	case starlark.Iterable:
+		// e.g. the result of range or a custom container type
+		d.setFieldIterator(path, dst, asIterable(v), opts)
+	case starlark.HasAttrs:
+		// e.g. a starlarkstruct.Struct or Module
+		d.setFieldDict(path, dst, false, attrsValue{v}, opts)
 	default:
 		d.recordTypeErr(path, v, dst)
 	}
@@ -265,6 +532,119 @@ func (d *decoder) setFieldStarlark(path string, fld reflect.Value, v starlark.Va
 	fld.Set(reflect.ValueOf(v))
 }
 
+var (
+	anyMapType    = reflect.TypeOf(map[string]any(nil))
+	anySliceType  = reflect.TypeOf([]any(nil))
+	bigIntPtrType = reflect.TypeOf((*big.Int)(nil))
+	bigIntType    = reflect.TypeOf(big.Int{})
+	bigFloatType  = reflect.TypeOf(big.Float{})
+	bigRatType    = reflect.TypeOf(big.Rat{})
+)
+
+func (d *decoder) setFieldAny(path string, fld reflect.Value, v starlark.Value, opts tagOpt) {
+	oriFld := fld
+
+	// support a single-level of indirection, in case the value may be None
+	if fld.Kind() == reflect.Pointer {
+		if fld.Type().Elem().Kind() != reflect.Interface {
+			d.recordTypeErr(path, v, fld)
+			return
+		}
+		if v == starlark.None {
+			fld.Set(reflect.Zero(fld.Type()))
+			return
+		}
+		fld = reflect.New(fld.Type().Elem()).Elem()
+	}
+
+	var newVal reflect.Value
+	if u := d.unions.lookup(fld.Type()); u != nil {
+		var dict dictGetSetter
+		switch v := v.(type) {
+		case *starlark.Dict:
+			dict = v
+		case starlark.String, starlark.Bytes, *starlark.List, *starlark.Set:
+			// not a struct-like value, even though it has attributes
+		case starlark.HasAttrs:
+			dict = attrsValue{v}
+		}
+		if dict != nil {
+			var ok bool
+			if newVal, ok = d.unionValue(path, u, dict, oriFld); !ok {
+				return
+			}
+		}
+	}
+	if !newVal.IsValid() {
+		if newVal = d.anyValue(path, v, oriFld, opts); !newVal.IsValid() {
+			return
+		}
+	}
+	fld.Set(newVal)
+
+	if oriFld.Kind() == reflect.Pointer {
+		if oriFld.IsNil() {
+			oriFld.Set(fld.Addr())
+		} else {
+			oriFld.Elem().Set(fld)
+		}
+	}
+}
+
+// returns the new Go value decoded from the starlark value v into its natural
+// Go type, which must be assignable to the interface goVal (or the interface
+// pointed to by goVal). It returns an invalid reflect.Value if it cannot be
+// decoded.
+func (d *decoder) anyValue(path string, v starlark.Value, goVal reflect.Value, opts tagOpt) reflect.Value {
+	ifaceTyp := goVal.Type()
+	if ifaceTyp.Kind() == reflect.Pointer {
+		ifaceTyp = ifaceTyp.Elem()
+	}
+
+	var typ reflect.Type
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return reflect.Zero(ifaceTyp)
+	case starlark.Bool:
+		typ = reflect.TypeOf(false)
+	case starlark.Bytes:
+		typ = reflect.TypeOf([]byte(nil))
+	case starlark.String:
+		typ = reflect.TypeOf("")
+	case starlark.Float:
+		typ = reflect.TypeOf(float64(0))
+	case starlark.Int:
+		typ = reflect.TypeOf(int64(0))
+		if _, ok := v.Int64(); !ok {
+			typ = bigIntPtrType
+			if d.anyBigIntType != nil {
+				typ = d.anyBigIntType
+			}
+		}
+	case *starlark.Dict:
+		typ = anyMapType
+	case *starlark.List, starlark.Tuple:
+		typ = anySliceType
+	case *starlark.Set:
+		typ = anySliceType
+		if d.anySetType != nil {
+			typ = d.anySetType
+		}
+	case starlark.IterableMapping:
+		typ = anyMapType
+	case starlark.Iterable:
+		typ = anySliceType
+	}
+	if typ == nil || !typ.AssignableTo(ifaceTyp) {
+		d.recordTypeErr(path, v, goVal)
+		return reflect.Value{}
+	}
+
+	newVal := reflect.New(typ).Elem()
+	d.fromStarlarkValue(path, v, newVal, opts)
+	return newVal
+}
+
 func (d *decoder) setFieldBool(path string, fld reflect.Value, b starlark.Bool) {
 	// support a single-level of indirection, in case the value may be None
 	if fld.Kind() == reflect.Pointer {
@@ -367,6 +747,85 @@ func (d *decoder) setFieldInt(path string, fld reflect.Value, i starlark.Int) {
 
 var epsilon = float64(math.Nextafter32(1, 2) - 1)
 
+func (d *decoder) setFieldBigNum(path string, fld reflect.Value, v starlark.Value) {
+	switch v.(type) {
+	case starlark.Int, starlark.Float:
+	default:
+		d.recordTypeErr(path, v, fld)
+		return
+	}
+
+	// support a single-level of indirection, in case the value may be None
+	if fld.Kind() == reflect.Pointer {
+		ptrToTyp := fld.Type().Elem()
+		if !isBigNumType(ptrToTyp) {
+			d.recordTypeErr(path, v, fld)
+			return
+		}
+
+		if fld.IsNil() {
+			// allocate the number value
+			fld.Set(reflect.New(ptrToTyp))
+		}
+		fld = fld.Elem()
+	}
+
+	if !isBigNumType(fld.Type()) {
+		d.recordTypeErr(path, v, fld)
+		return
+	}
+
+	var f float64
+	sf, isFloat := v.(starlark.Float)
+	if isFloat {
+		f = float64(sf)
+	}
+
+	switch x := fld.Addr().Interface().(type) {
+	case *big.Int:
+		if !isFloat {
+			x.Set(v.(starlark.Int).BigInt())
+			return
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) || math.Trunc(f) != f {
+			d.recordNumberErr(path, v, fld, NumCannotExactlyRepresent)
+			return
+		}
+		new(big.Float).SetFloat64(f).Int(x)
+
+	case *big.Float:
+		// an existing big.Float value may have a non-zero precision that cannot
+		// represent the number, so it is converted with that precision first and
+		// the field is left unmodified if the result is not exact.
+		tmp := new(big.Float).SetPrec(x.Prec()).SetMode(x.Mode())
+		if !isFloat {
+			tmp.SetInt(v.(starlark.Int).BigInt())
+		} else {
+			if math.IsNaN(f) {
+				d.recordNumberErr(path, v, fld, NumCannotExactlyRepresent)
+				return
+			}
+			tmp.SetFloat64(f)
+		}
+		if tmp.Acc() != big.Exact {
+			d.recordNumberErr(path, v, fld, NumCannotExactlyRepresent)
+			return
+		}
+		x.Set(tmp)
+
+	case *big.Rat:
+		if !isFloat {
+			x.SetInt(v.(starlark.Int).BigInt())
+			return
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			d.recordNumberErr(path, v, fld, NumCannotExactlyRepresent)
+			return
+		}
+		x.SetFloat64(f)
+	}
+}
+
 func (d *decoder) setFieldFloat(path string, fld reflect.Value, f starlark.Float) {
 	// support a single-level of indirection, in case the value may be None
 	if fld.Kind() == reflect.Pointer {
@@ -479,8 +938,16 @@ func (d *decoder) setFieldFloat(path string, fld reflect.Value, f starlark.Float
 	}
 }
 
-func (d *decoder) setFieldDict(path string, fld reflect.Value, embedded bool, dict dictGetSetter) (didSet bool) {
+func (d *decoder) setFieldDict(path string, fld reflect.Value, embedded bool, dict dictGetSetter, opts tagOpt) (didSet bool) {
+	if fldTyp := fld.Type(); !embedded && (fldTyp.Kind() == reflect.Map || fldTyp.Kind() == reflect.Pointer && fldTyp.Elem().Kind() == reflect.Map) {
+		if mapping, ok := dict.(starlark.IterableMapping); ok {
+			d.setFieldMap(path, fld, mapping, opts)
+			return true
+		}
+	}
+
 	var ptrToStrct reflect.Value
+	var allocated bool
 
 	// support a single-level of indirection, in case the value may be None
 	if fld.Kind() == reflect.Pointer {
@@ -500,6 +967,7 @@ func (d *decoder) setFieldDict(path string, fld reflect.Value, embedded bool, di
 			// allocate the struct value, but do not set it yet on the pointer, will
 			// only set it if something was set on the struct.
 			fld = reflect.New(ptrToTyp)
+			allocated = true
 		}
 		fld = fld.Elem()
 	}
@@ -513,19 +981,133 @@ func (d *decoder) setFieldDict(path string, fld reflect.Value, embedded bool, di
 		return didSet
 	}
 
+	// the discriminator key of a union is consumed by the union decoding
+	discriminator := d.discriminator
+	d.discriminator = ""
+
+	nerrs := len(d.errs)
 	didSet = d.walkStructDecode(path, fld, dict)
+	if !embedded {
+		r, hasRemain, err := remainField(path, fld)
+		if err != nil {
+			d.recordErr(err)
+		}
+		if hasRemain {
+			// leave the field unmodified if there is no remaining key
+			if rest := d.remainingKeys(fld, dict, discriminator); rest != nil {
+				d.setFieldRemain(r, rest)
+				didSet = true
+			}
+		}
+		if d.strict {
+			d.checkUnknownKeys(path, fld, dict, discriminator, hasRemain)
+		}
+	}
+	// the hooks of an embedded struct are promoted to the parent struct, so
+	// they are only called once the parent is decoded, and only if decoding the
+	// struct succeeded and it is stored in the field (an allocated struct is
+	// only stored if something was set on it).
+	if !embedded && len(d.errs) == nerrs && (didSet || !allocated) {
+		d.afterDecode(path, fld)
+	}
 	if didSet && ptrToStrct.Kind() == reflect.Pointer {
 		ptrToStrct.Set(fld.Addr())
 	}
 	return didSet
 }
 
-func (d *decoder) setFieldList(path string, fld reflect.Value, list *starlark.List) {
-	d.setFieldIterator(path, fld, list)
+func (d *decoder) setFieldMap(path string, fld reflect.Value, dict starlark.IterableMapping, opts tagOpt) {
+	// support a single-level of indirection, in case the value may be None (even
+	// though it wouldn't be necessary as map can be nil, but for consistency
+	// with other types)
+	if fld.Kind() == reflect.Pointer {
+		ptrToTyp := fld.Type().Elem()
+
+		if !isDictMapType(ptrToTyp) {
+			d.recordTypeErr(path, dict, fld)
+			return
+		}
+
+		if fld.IsNil() {
+			// allocate the pointer to map value
+			fld.Set(reflect.New(ptrToTyp))
+		}
+		fld = fld.Elem()
+	}
+
+	if !isDictMapType(fld.Type()) {
+		d.recordTypeErr(path, dict, fld)
+		return
+	}
+	keyTyp, elemTyp := fld.Type().Key(), fld.Type().Elem()
+
+	// mimic the JSON unmarshal behaviour: if the map is nil, allocate one,
+	// otherwise the existing map is reused, keeping existing entries. Each
+	// value is decoded into a new zero value of the map's element type.
+	items := dict.Items()
+	if fld.IsNil() {
+		mapTyp := reflect.MapOf(keyTyp, elemTyp)
+		fld.Set(reflect.MakeMapWithSize(mapTyp, len(items)))
+	}
+
+	for _, kv := range items {
+		path := fmt.Sprintf("%s[%s]", path, kv[0].String())
+
+		// do not store the entry in the map if the key or value failed to
+		// convert.
+		errCount := len(d.errs)
+		newKey := reflect.New(keyTyp).Elem()
+		d.fromStarlarkKey(path, kv[0], newKey)
+		if len(d.errs) == errCount && !newKey.Comparable() {
+			d.recordTypeErr(path, kv[0], newKey)
+		}
+		newElem := reflect.New(elemTyp).Elem()
+		d.fromStarlarkValue(path, kv[1], newElem, opts.shift())
+		if len(d.errs) > errCount {
+			continue
+		}
+		fld.SetMapIndex(newKey, newElem)
+	}
+}
+
+// decodes the starlark dict key or set element starVal into the Go map key
+// dst. A Tuple is
+// decoded positionally into an array (element by element) or a struct (field
+// by field), otherwise the key is decoded as any other starlark value. Errors
+// are reported at the path of the key, not of the individual element or
+// field.
+func (d *decoder) fromStarlarkKey(path string, starVal starlark.Value, dst reflect.Value) {
+	tup, ok := starVal.(starlark.Tuple)
+	if !ok || (dst.Kind() != reflect.Array && dst.Kind() != reflect.Struct) {
+		d.fromStarlarkValue(path, starVal, dst, nil)
+		return
+	}
+
+	n := dst.Len
+	elem := dst.Index
+	if dst.Kind() == reflect.Struct {
+		n = dst.NumField
+		elem = dst.Field
+	}
+	if len(tup) != n() {
+		if dst.Kind() == reflect.Array {
+			d.recordLengthErr(path, tup, dst, n(), len(tup))
+		} else {
+			d.recordTypeErr(path, tup, dst)
+		}
+		return
+	}
+	for i, v := range tup {
+		d.fromStarlarkKey(path, v, elem(i))
+	}
 }
 
-func (d *decoder) setFieldTuple(path string, fld reflect.Value, tup starlark.Tuple) {
-	d.setFieldIterator(path, fld, tup)
+func (d *decoder) setFieldList(path string, fld reflect.Value, list *starlark.List, opts tagOpt) {
+	d.setFieldIterator(path, fld, list, opts)
+}
+
+func (d *decoder) setFieldTuple(path string, fld reflect.Value, tup starlark.Tuple, opts tagOpt) {
+	d.setFieldIterator(path, fld, tup, opts)
 }
 
 type iterable interface {
@@ -534,18 +1116,50 @@ type iterable interface {
 	Len() int
 }
 
-func (d *decoder) setFieldIterator(path string, fld reflect.Value, iter iterable) {
+// returns v as an iterable. If v does not implement the Len method, its
+// values are collected first so that its length is known.
+func asIterable(v starlark.Iterable) iterable {
+	if iter, ok := v.(iterable); ok {
+		return iter
+	}
+
+	var vals []starlark.Value
+	it := v.Iterate()
+	defer it.Done()
+	var x starlark.Value
+	for it.Next(&x) {
+		vals = append(vals, x)
+	}
+	return collectedIterable{Iterable: v, vals: vals}
+}
+
+// collectedIterable wraps a starlark.Iterable that does not implement Len,
+// with its values collected in a Tuple.
+type collectedIterable struct {
+	starlark.Iterable
+	vals starlark.Tuple
+}
+
+func (c collectedIterable) Len() int                   { return len(c.vals) }
+func (c collectedIterable) Iterate() starlark.Iterator { return c.vals.Iterate() }
+
+func (d *decoder) setFieldIterator(path string, fld reflect.Value, iter iterable, opts tagOpt) {
 	// support a single-level of indirection, in case the value may be None (even
 	// though it wouldn't be necessary as slice can be nil, but for consistency
 	// with other types)
 	if fld.Kind() == reflect.Pointer {
 		ptrToTyp := fld.Type().Elem()
 
-		// must be a slice
-		if ptrToTyp.Kind() != reflect.Slice {
+		// must be a slice or an array
+		if ptrToTyp.Kind() != reflect.Slice && ptrToTyp.Kind() != reflect.Array {
 			d.recordTypeErr(path, iter, fld)
 			return
 		}
+		if ptrToTyp.Kind() == reflect.Array {
+			// the pointer is only allocated if the array is decoded successfully
+			d.setFieldArray(path, fld, iter, opts)
+			return
+		}
 
 		if fld.IsNil() {
 			// allocate the pointer to slice value
@@ -554,6 +1168,10 @@ func (d *decoder) setFieldIterator(path string, fld reflect.Value, iter iterable
 		fld = fld.Elem()
 	}
 
+	if fld.Kind() == reflect.Array {
+		d.setFieldArray(path, fld, iter, opts)
+		return
+	}
 	if fld.Kind() != reflect.Slice {
 		d.recordTypeErr(path, iter, fld)
 		return
@@ -582,18 +1200,56 @@ func (d *decoder) setFieldIterator(path string, fld reflect.Value, iter iterable
 	var i int
 	for it.Next(&newVal) {
 		newElem := reflect.New(elemTyp).Elem()
-		d.fromStarlarkValue(fmt.Sprintf("%s[%d]", path, i), newVal, newElem)
+		d.fromStarlarkValue(fmt.Sprintf("%s[%d]", path, i), newVal, newElem, opts.shift())
 		fld.Set(reflect.Append(fld, newElem))
 		i++
 	}
 }
 
+// decodes iter into the array fld, or the array pointed to by fld. The array
+// is decoded into a new array that is only stored if it is decoded without
+// error, so that the field is left unmodified (and a nil pointer is left nil)
+// otherwise.
+func (d *decoder) setFieldArray(path string, fld reflect.Value, iter iterable, opts tagOpt) {
+	arrTyp := fld.Type()
+	if arrTyp.Kind() == reflect.Pointer {
+		arrTyp = arrTyp.Elem()
+	}
+	newArr := reflect.New(arrTyp)
+	if count := iter.Len(); count != arrTyp.Len() {
+		d.recordLengthErr(path, iter, newArr.Elem(), arrTyp.Len(), count)
+		return
+	}
+
+	nerrs := len(d.errs)
+	it := iter.Iterate()
+	defer it.Done()
+	var newVal starlark.Value
+	var i int
+	for it.Next(&newVal) {
+		d.fromStarlarkValue(fmt.Sprintf("%s[%d]", path, i), newVal, newArr.Elem().Index(i), opts.shift())
+		i++
+	}
+	if len(d.errs) != nerrs {
+		return
+	}
+
+	if fld.Kind() == reflect.Pointer {
+		if fld.IsNil() {
+			fld.Set(newArr)
+			return
+		}
+		fld = fld.Elem()
+	}
+	fld.Set(newArr.Elem())
+}
+
 var trueValue = reflect.ValueOf(true)
 
-func (d *decoder) setFieldSet(path string, fld reflect.Value, set *starlark.Set) {
-	if fldTyp := fld.Type(); fldTyp.Kind() == reflect.Slice || fldTyp.Kind() == reflect.Pointer && fldTyp.Elem().Kind() == reflect.Slice {
+func (d *decoder) setFieldSet(path string, fld reflect.Value, set *starlark.Set, opts tagOpt) {
+	if fldTyp := fld.Type(); isSliceOrArrayType(fldTyp) || fldTyp.Kind() == reflect.Pointer && isSliceOrArrayType(fldTyp.Elem()) {
 		// same as decoding a List/Tuple
-		d.setFieldIterator(path, fld, set)
+		d.setFieldIterator(path, fld, set, opts)
 		return
 	}
 
@@ -636,10 +1292,23 @@ func (d *decoder) setFieldSet(path string, fld reflect.Value, set *starlark.Set)
 	var newVal starlark.Value
 	var i int
 	for it.Next(&newVal) {
+		path := fmt.Sprintf("%s[%d]", path, i)
+		i++
+
+		// do not store the key in the map if it failed to convert.
+		errCount := len(d.errs)
 		newKey := reflect.New(keyTyp).Elem()
-		d.fromStarlarkValue(fmt.Sprintf("%s[%d]", path, i), newVal, newKey)
+		d.fromStarlarkKey(path, newVal, newKey)
+		if len(d.errs) > errCount {
+			continue
+		}
+		if !newKey.Comparable() {
+			// can happen if the key type is an interface, e.g. a Tuple decoded as
+			// []any.
+			d.recordTypeErr(path, newVal, newKey)
+			continue
+		}
 		fld.SetMapIndex(newKey, trueValue)
-		i++
 	}
 }
 
@@ -652,31 +1321,45 @@ func (d *decoder) setFieldString(path string, fld reflect.Value, s starlark.Stri
 }
 
 func (d *decoder) setFieldBytesOrString(path string, fld reflect.Value, v starlark.Value, s string) {
-	byteSlice := isByteSliceType(fld.Type())
+	byteSlice, byteArray := isByteSliceType(fld.Type()), isByteArrayType(fld.Type())
 
 	// support a single-level of indirection, in case the value may be None
 	if fld.Kind() == reflect.Pointer {
 		ptrToTyp := fld.Type().Elem()
-		byteSlice = isByteSliceType(ptrToTyp)
-		if ptrToTyp.Kind() != reflect.String && !byteSlice {
+		byteSlice, byteArray = isByteSliceType(ptrToTyp), isByteArrayType(ptrToTyp)
+		if ptrToTyp.Kind() != reflect.String && !byteSlice && !byteArray {
 			d.recordTypeErr(path, v, fld)
 			return
 		}
+		if byteArray && len(s) != ptrToTyp.Len() {
+			// leave the pointer unmodified, as for any other array
+			d.recordLengthErr(path, v, reflect.New(ptrToTyp).Elem(), ptrToTyp.Len(), len(s))
+			return
+		}
 
 		if fld.IsNil() {
-			// allocate the *string or *[]byte value
+			// allocate the *string, *[]byte or *[N]byte value
 			fld.Set(reflect.New(ptrToTyp))
 		}
 		fld = fld.Elem()
 	}
 
-	if fld.Kind() != reflect.String && !byteSlice {
+	if fld.Kind() != reflect.String && !byteSlice && !byteArray {
 		d.recordTypeErr(path, v, fld)
 		return
 	}
-	if byteSlice {
+	switch {
+	case byteSlice:
 		fld.SetBytes([]byte(s))
-	} else {
+	case byteArray:
+		if len(s) != fld.Len() {
+			d.recordLengthErr(path, v, fld, fld.Len(), len(s))
+			return
+		}
+		for i := 0; i < len(s); i++ {
+			fld.Index(i).SetUint(uint64(s[i]))
+		}
+	default:
 		fld.SetString(s)
 	}
 }
@@ -719,6 +1402,7 @@ func (d *decoder) recordCustomConvErr(path string, starVal starlark.Value, goVal
 
 func (d *decoder) recordNumberErr(path string, starNum starlark.Value, goVal reflect.Value, reason NumberFailReason) {
 	err := &NumberError{
+		Op:      OpFromStarlark,
 		Reason:  reason,
 		Path:    path,
 		StarNum: starNum,
@@ -727,6 +1411,35 @@ func (d *decoder) recordNumberErr(path string, starNum starlark.Value, goVal ref
 	d.recordErr(err)
 }
 
+func (d *decoder) recordParseErr(path string, starVal starlark.Value, goVal reflect.Value, e error) {
+	err := &ParseError{
+		Path:    path,
+		StarVal: starVal,
+		GoVal:   goVal,
+		Err:     e,
+	}
+	d.recordErr(err)
+}
+
+func (d *decoder) recordLengthErr(path string, starVal starlark.Value, goVal reflect.Value, want, got int) {
+	err := &LengthError{
+		Path:     path,
+		StarVal:  starVal,
+		GoVal:    goVal,
+		Expected: want,
+		Actual:   got,
+	}
+	d.recordErr(err)
+}
+
+func (d *decoder) recordMissingFieldErr(path, key string) {
+	err := &MissingFieldError{
+		Path: path,
+		Key:  key,
+	}
+	d.recordErr(err)
+}
+
 func (d *decoder) recordErr(err error) {
 	if d.maxErrs > 0 && len(d.errs) == d.maxErrs {
 		d.errs = append(d.errs, errors.New("maximum number of errors reached"))
@@ -747,6 +1460,63 @@ func isByteSliceType(t reflect.Type) bool {
 	return t.Elem().Kind() == reflect.Uint8
 }
 
+// returns true if t is a map type that can be decoded from and encoded to a
+// starlark Dict.
+func isDictMapType(t reflect.Type) bool {
+	if t.Kind() != reflect.Map {
+		return false
+	}
+	return isDictKeyType(t.Key())
+}
+
+// returns true if t is a Go type that can be used as key of a map that
+// converts to a starlark Dict. Arrays and structs are supported as long as
+// their elements (or all of their fields, which must be exported) are
+// themselves supported key types.
+func isDictKeyType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Array:
+		return isDictKeyType(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			fld := t.Field(i)
+			if !fld.IsExported() || !isDictKeyType(fld.Type) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func isBigNumType(t reflect.Type) bool {
+	return t == bigIntType || t == bigFloatType || t == bigRatType
+}
+
+func isTOrPtrTBigNumType(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return isBigNumType(t)
+}
+
+func isByteArrayType(t reflect.Type) bool {
+	if t.Kind() != reflect.Array {
+		return false
+	}
+	return t.Elem().Kind() == reflect.Uint8
+}
+
+func isSliceOrArrayType(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+}
+
 func isSetMapType(t reflect.Type) bool {
 	if t.Kind() != reflect.Map {
 		return false
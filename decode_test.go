@@ -3,13 +3,18 @@ package starstruct
 import (
 	"errors"
 	"fmt"
+	"io"
 	"math"
+	"math/big"
+	"net/netip"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	startime "go.starlark.net/lib/time"
 	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
 )
 
 func TestFromStarlark(t *testing.T) {
@@ -63,9 +68,51 @@ func TestFromStarlark(t *testing.T) {
 	}
 
 	type StrctSet struct {
-		M    map[string]bool
-		Sl   []string
-		Mptr *map[int]bool
+		M      map[string]bool
+		Sl     []string
+		Mptr   *map[int]bool
+		Routes map[KeyRoute]bool
+		Pairs  map[[2]string]bool
+	}
+
+	type StrctMap struct {
+		M      map[string]int
+		Mptr   *map[string]string
+		Strct  map[string]StrctBool
+		Ptrs   map[string]*StrctBool
+		Named  map[myString]myInt
+		Set    map[string]bool
+		Slices map[string][]string
+	}
+
+	type StrctKeys struct {
+		Ints   map[int]string
+		Uints  map[uint16]bool
+		Bools  map[bool]int
+		Floats map[float64]int
+		Arrays map[[2]string]int
+		Routes map[KeyRoute]StrctBool
+		Nested map[KeyNested]*int
+		Unsupp map[*int]bool
+		Unexp  map[KeyUnexp]bool
+	}
+
+	type StrctArray struct {
+		Hash   [4]byte
+		HPtr   *[4]byte
+		Ints   [3]int
+		IPtr   *[2]int
+		Matrix [2][2]float64
+		Empty  [0]string
+	}
+
+	type StrctAny struct {
+		Any      any
+		AnyPtr   *any
+		Stringer fmt.Stringer
+		Err      error
+		Map      map[string]any
+		Slice    []any
 	}
 
 	type StrctEmbedDuration struct {
@@ -296,9 +343,77 @@ func TestFromStarlark(t *testing.T) {
 		{"set into *map", M{"mptr": set(starlark.MakeInt(1), starlark.MakeInt(2))}, &StrctSet{}, StrctSet{Mptr: &map[int]bool{1: true, 2: true}}, ``},
 		{"None into *map", M{"mptr": starlark.None}, &StrctSet{Mptr: &map[int]bool{}}, StrctSet{Mptr: nil}, ``},
 		{"set mixed values", M{"m": set(starlark.String("a"), starlark.MakeInt(1))}, &StrctSet{}, nil, `M[1]: cannot convert Starlark int to Go type string`},
+		{"set into struct keys", M{"routes": set(tup(starlark.MakeInt(80), starlark.String("tcp")))}, &StrctSet{}, StrctSet{Routes: map[KeyRoute]bool{{80, "tcp"}: true}}, ``},
+		{"set into array keys", M{"pairs": set(tup(starlark.String("a"), starlark.String("b")))}, &StrctSet{}, StrctSet{Pairs: map[[2]string]bool{{"a", "b"}: true}}, ``},
+		{"set into array keys invalid length", M{"pairs": set(tup(starlark.String("a")))}, &StrctSet{}, StrctSet{Pairs: map[[2]string]bool{}}, `Pairs[0]: cannot assign Starlark tuple to Go type [2]string: expected length 2, got 1`},
 		{"set into non-map", M{"b": set(starlark.String("a"), starlark.String("b"))}, &StrctBool{}, nil, `B: cannot convert Starlark set to Go type bool`},
 		{"set into non-map pointer", M{"bptr": set(starlark.String("a"), starlark.String("b"))}, &StrctBool{}, nil, `Bptr: cannot convert Starlark set to Go type *bool`},
 
+		{"dict into map", M{"m": dict(M{"a": starlark.MakeInt(1), "b": starlark.MakeInt(2)})}, &StrctMap{}, StrctMap{M: map[string]int{"a": 1, "b": 2}}, ``},
+		{"dict into existing map", M{"m": dict(M{"a": starlark.MakeInt(1)})}, &StrctMap{M: map[string]int{"a": 3, "c": 4}}, StrctMap{M: map[string]int{"a": 1, "c": 4}}, ``},
+		{"empty dict into nil map", M{"m": dict(M{})}, &StrctMap{}, StrctMap{M: map[string]int{}}, ``},
+		{"dict into *map", M{"mptr": dict(M{"a": starlark.String("b")})}, &StrctMap{}, StrctMap{Mptr: &map[string]string{"a": "b"}}, ``},
+		{"None into *map of dict", M{"mptr": starlark.None}, &StrctMap{Mptr: &map[string]string{}}, StrctMap{}, ``},
+		{"dict into map of struct", M{"strct": dict(M{"x": dict(M{"b": starlark.True})})}, &StrctMap{}, StrctMap{Strct: map[string]StrctBool{"x": {B: true}}}, ``},
+		{"dict into existing map of struct", M{"strct": dict(M{"x": dict(M{"b": starlark.True})})}, &StrctMap{Strct: map[string]StrctBool{"x": {Bptr: &truev}}}, StrctMap{Strct: map[string]StrctBool{"x": {B: true}}}, ``},
+		{"dict into map of *struct", M{"ptrs": dict(M{"x": dict(M{"b": starlark.True}), "y": starlark.None})}, &StrctMap{}, StrctMap{Ptrs: map[string]*StrctBool{"x": {B: true}, "y": nil}}, ``},
+		{"dict into map of named types", M{"named": dict(M{"a": starlark.MakeInt(1)})}, &StrctMap{}, StrctMap{Named: map[myString]myInt{"a": 1}}, ``},
+		{"dict into map of bool", M{"set": dict(M{"a": starlark.False})}, &StrctMap{}, StrctMap{Set: map[string]bool{"a": false}}, ``},
+		{"dict into map of slices", M{"slices": dict(M{"a": list(starlark.String("b"))})}, &StrctMap{}, StrctMap{Slices: map[string][]string{"a": {"b"}}}, ``},
+		{"dict into map invalid value", M{"m": dict(M{"a": starlark.MakeInt(1), "b": starlark.String("x")})}, &StrctMap{}, StrctMap{M: map[string]int{"a": 1}}, `M["b"]: cannot convert Starlark string to Go type int`},
+		{"dict into map invalid key", M{"m": dictKV(starlark.MakeInt(1), starlark.MakeInt(1))}, &StrctMap{}, StrctMap{M: map[string]int{}}, `M[1]: cannot convert Starlark int to Go type string`},
+		{"dict into non-map", M{"b": dict(M{"a": starlark.True})}, &StrctBool{}, nil, `B: cannot convert Starlark dict to Go type bool`},
+		{"dict into map string key into int", M{"mptr": dict(M{"a": starlark.True})}, &StrctSet{}, StrctSet{Mptr: &map[int]bool{}}, `Mptr["a"]: cannot convert Starlark string to Go type int`},
+		{"dict into unsupported map key", M{"unsupp": dict(M{"a": starlark.True})}, &StrctKeys{}, nil, `Unsupp: cannot convert Starlark dict to Go type map[*int]bool`},
+		{"dict into unexported struct key", M{"unexp": dictKV(tup(starlark.MakeInt(1)), starlark.True)}, &StrctKeys{}, nil, `Unexp: cannot convert Starlark dict to Go type map[starstruct.KeyUnexp]bool`},
+		{"dict into int keys", M{"ints": dictKV(starlark.MakeInt(1), starlark.String("a"), starlark.MakeInt(-2), starlark.String("b"))}, &StrctKeys{}, StrctKeys{Ints: map[int]string{1: "a", -2: "b"}}, ``},
+		{"dict into uint16 keys", M{"uints": dictKV(starlark.MakeInt(80), starlark.True)}, &StrctKeys{}, StrctKeys{Uints: map[uint16]bool{80: true}}, ``},
+		{"dict into uint16 keys out of range", M{"uints": dictKV(starlark.MakeInt(-1), starlark.True)}, &StrctKeys{}, StrctKeys{Uints: map[uint16]bool{}}, `Uints[-1]: cannot assign Starlark int to Go type uint16: value out of range`},
+		{"dict into bool keys", M{"bools": dictKV(starlark.True, starlark.MakeInt(1))}, &StrctKeys{}, StrctKeys{Bools: map[bool]int{true: 1}}, ``},
+		{"dict into float keys", M{"floats": dictKV(starlark.Float(1.5), starlark.MakeInt(1))}, &StrctKeys{}, StrctKeys{Floats: map[float64]int{1.5: 1}}, ``},
+		{"dict into array keys", M{"arrays": dictKV(tup(starlark.String("a"), starlark.String("b")), starlark.MakeInt(1))}, &StrctKeys{}, StrctKeys{Arrays: map[[2]string]int{{"a", "b"}: 1}}, ``},
+		{"dict into array keys wrong length", M{"arrays": dictKV(tup(starlark.String("a")), starlark.MakeInt(1))}, &StrctKeys{}, StrctKeys{Arrays: map[[2]string]int{}}, `Arrays[("a",)]: cannot assign Starlark tuple to Go type [2]string: expected length 2, got 1`},
+		{"dict into array keys not a tuple", M{"arrays": dictKV(starlark.String("a"), starlark.MakeInt(1))}, &StrctKeys{}, StrctKeys{Arrays: map[[2]string]int{}}, `Arrays["a"]: cannot convert Starlark string to Go type [2]string`},
+		{"dict into struct keys", M{"routes": dictKV(tup(starlark.MakeInt(80), starlark.String("tcp")), dict(M{"b": starlark.True}))}, &StrctKeys{}, StrctKeys{Routes: map[KeyRoute]StrctBool{{80, "tcp"}: {B: true}}}, ``},
+		{"dict into struct keys invalid field", M{"routes": dictKV(tup(starlark.MakeInt(80), starlark.MakeInt(1)), dict(M{}))}, &StrctKeys{}, StrctKeys{Routes: map[KeyRoute]StrctBool{}}, `Routes[(80, 1)]: cannot convert Starlark int to Go type string`},
+		{"dict into nested keys", M{"nested": dictKV(tup(tup(starlark.MakeInt(1), starlark.MakeInt(2)), starlark.True), starlark.None)}, &StrctKeys{}, StrctKeys{Nested: map[KeyNested]*int{{Ints: [2]int{1, 2}, B: true}: nil}}, ``},
+
+		{"bytes into byte array", M{"hash": starlark.Bytes("abcd")}, &StrctArray{}, StrctArray{Hash: [4]byte{'a', 'b', 'c', 'd'}}, ``},
+		{"string into byte array", M{"hash": starlark.String("abcd")}, &StrctArray{}, StrctArray{Hash: [4]byte{'a', 'b', 'c', 'd'}}, ``},
+		{"bytes into *byte array", M{"hptr": starlark.Bytes("abcd")}, &StrctArray{}, StrctArray{HPtr: &[4]byte{'a', 'b', 'c', 'd'}}, ``},
+		{"bytes into byte array too short", M{"hash": starlark.Bytes("abc")}, &StrctArray{Hash: [4]byte{1}}, StrctArray{Hash: [4]byte{1}}, `Hash: cannot assign Starlark bytes to Go type [4]uint8: expected length 4, got 3`},
+		{"list into byte array", M{"hash": list(starlark.MakeInt(1), starlark.MakeInt(2), starlark.MakeInt(3), starlark.MakeInt(4))}, &StrctArray{}, StrctArray{Hash: [4]byte{1, 2, 3, 4}}, ``},
+		{"list into array", M{"ints": list(starlark.MakeInt(1), starlark.MakeInt(2), starlark.MakeInt(3))}, &StrctArray{Ints: [3]int{4, 5, 6}}, StrctArray{Ints: [3]int{1, 2, 3}}, ``},
+		{"tuple into *array", M{"iptr": tup(starlark.MakeInt(1), starlark.MakeInt(2))}, &StrctArray{}, StrctArray{IPtr: &[2]int{1, 2}}, ``},
+		{"None into *array", M{"iptr": starlark.None}, &StrctArray{IPtr: &[2]int{1, 2}}, StrctArray{}, ``},
+		{"set into array", M{"ints": set(starlark.MakeInt(1), starlark.MakeInt(2), starlark.MakeInt(3))}, &StrctArray{}, StrctArray{Ints: [3]int{1, 2, 3}}, ``},
+		{"list into nested arrays", M{"matrix": list(tup(starlark.Float(1), starlark.Float(2)), tup(starlark.Float(3), starlark.MakeInt(4)))}, &StrctArray{}, StrctArray{Matrix: [2][2]float64{{1, 2}, {3, 4}}}, ``},
+		{"empty list into empty array", M{"empty": list()}, &StrctArray{}, StrctArray{}, ``},
+		{"list into array too long", M{"ints": list(starlark.MakeInt(1), starlark.MakeInt(2), starlark.MakeInt(3), starlark.MakeInt(4))}, &StrctArray{}, StrctArray{}, `Ints: cannot assign Starlark list to Go type [3]int: expected length 3, got 4`},
+		{"list into nested array too short", M{"matrix": list(tup(starlark.Float(1), starlark.Float(2)), tup(starlark.Float(3)))}, &StrctArray{}, StrctArray{}, `Matrix[1]: cannot assign Starlark tuple to Go type [2]float64: expected length 2, got 1`},
+		{"list into array invalid element", M{"ints": list(starlark.MakeInt(1), starlark.String("a"), starlark.MakeInt(3))}, &StrctArray{}, StrctArray{}, `Ints[1]: cannot convert Starlark string to Go type int`},
+		{"int into array", M{"ints": starlark.MakeInt(1)}, &StrctArray{}, nil, `Ints: cannot convert Starlark int to Go type [3]int`},
+
+		{"None into any", M{"any": starlark.None}, &StrctAny{Any: 1}, StrctAny{}, ``},
+		{"bool into any", M{"any": starlark.True}, &StrctAny{}, StrctAny{Any: true}, ``},
+		{"string into any", M{"any": starlark.String("a")}, &StrctAny{}, StrctAny{Any: "a"}, ``},
+		{"bytes into any", M{"any": starlark.Bytes("a")}, &StrctAny{}, StrctAny{Any: []byte("a")}, ``},
+		{"int into any", M{"any": starlark.MakeInt(-1)}, &StrctAny{}, StrctAny{Any: int64(-1)}, ``},
+		{"big int into any", M{"any": starlark.MakeBigInt(tooBig)}, &StrctAny{}, StrctAny{Any: tooBig}, ``},
+		{"float into any", M{"any": starlark.Float(1.5)}, &StrctAny{}, StrctAny{Any: 1.5}, ``},
+		{"dict into any", M{"any": dict(M{"a": list(starlark.MakeInt(1), dict(M{"b": starlark.None}))})}, &StrctAny{}, StrctAny{Any: map[string]any{"a": []any{int64(1), map[string]any{"b": nil}}}}, ``},
+		{"dict with int keys into any", M{"any": dictKV(starlark.MakeInt(1), starlark.True)}, &StrctAny{}, StrctAny{Any: map[string]any{}}, `Any[1]: cannot convert Starlark int to Go type string`},
+		{"list into any", M{"any": list(starlark.String("a"), tup(starlark.Float(1)))}, &StrctAny{}, StrctAny{Any: []any{"a", []any{1.0}}}, ``},
+		{"tuple into any", M{"any": tup(starlark.True)}, &StrctAny{}, StrctAny{Any: []any{true}}, ``},
+		{"set into any", M{"any": set(starlark.String("a"))}, &StrctAny{}, StrctAny{Any: []any{"a"}}, ``},
+		{"unknown starlark value into any", M{"any": dummyValue{}}, &StrctAny{}, nil, `Any: cannot convert Starlark dummy to Go type interface {}`},
+		{"int into *any", M{"anyptr": starlark.MakeInt(1)}, &StrctAny{}, StrctAny{AnyPtr: anyptr(int64(1))}, ``},
+		{"None into *any", M{"anyptr": starlark.None}, &StrctAny{AnyPtr: anyptr(1)}, StrctAny{}, ``},
+		{"int into Stringer", M{"stringer": starlark.MakeInt(1)}, &StrctAny{}, nil, `Stringer: cannot convert Starlark int to Go type fmt.Stringer`},
+		{"None into error", M{"err": starlark.None}, &StrctAny{Err: io.EOF}, StrctAny{}, ``},
+		{"dict into map of any", M{"map": dict(M{"a": starlark.MakeInt(1)})}, &StrctAny{}, StrctAny{Map: map[string]any{"a": int64(1)}}, ``},
+		{"list into slice of any", M{"slice": list(starlark.MakeInt(1), starlark.None)}, &StrctAny{}, StrctAny{Slice: []any{int64(1), nil}}, ``},
+
 		{"decode into starlark value", M{"star": starlark.None}, &StrctStarval{}, StrctStarval{Star: starlark.None}, ``},
 		{"decode into starlark value pointer", M{"starptr": starlark.MakeInt(1)}, &StrctStarval{}, StrctStarval{StarPtr: starptr(starlark.MakeInt(1))}, ``},
 		{"decode into starlark **Value", M{"star2ptr": starlark.MakeInt(1)}, &StrctStarval{}, nil, `Star2Ptr: cannot convert Starlark int to Go type **starlark.Value`},
@@ -349,6 +464,485 @@ StrctStr.S2ptr: cannot convert Starlark string to Go type **string`},
 	}
 }
 
+func TestFromStarlark_LengthError(t *testing.T) {
+	type S struct {
+		A [2]int
+	}
+	var s S
+	err := FromStarlark(M{"A": list(starlark.MakeInt(1))}, &s)
+	require.Error(t, err)
+
+	var le *LengthError
+	require.ErrorAs(t, err, &le)
+	require.Equal(t, "A", le.Path)
+	require.Equal(t, 2, le.Expected)
+	require.Equal(t, 1, le.Actual)
+}
+
+func TestFromStarlark_ArrayUnmodifiedOnError(t *testing.T) {
+	type S struct {
+		A  [3]int
+		P  *[2]int
+		Q  *[2]int
+		B  *[2]byte
+		NP *[2]int
+	}
+	s := S{A: [3]int{7, 7, 7}, Q: &[2]int{7, 7}}
+	q := s.Q
+	err := FromStarlark(M{
+		"A":  list(starlark.MakeInt(1), starlark.String("x"), starlark.MakeInt(3)),
+		"P":  list(starlark.MakeInt(1)),
+		"Q":  list(starlark.String("x"), starlark.MakeInt(2)),
+		"B":  starlark.String("abc"),
+		"NP": list(starlark.MakeInt(1), starlark.MakeInt(2)),
+	}, &s)
+	require.Error(t, err)
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	require.Len(t, errs, 4)
+
+	require.Equal(t, [3]int{7, 7, 7}, s.A)
+	require.Nil(t, s.P)
+	require.Same(t, q, s.Q)
+	require.Equal(t, [2]int{7, 7}, *s.Q)
+	require.Nil(t, s.B)
+	require.Equal(t, &[2]int{1, 2}, s.NP)
+}
+
+func TestFromStarlark_AnyOptions(t *testing.T) {
+	type S struct {
+		Set any
+		Big any
+		Tup any
+	}
+
+	t.Run("set type", func(t *testing.T) {
+		var s S
+		err := FromStarlark(M{"Set": set(starlark.String("a"), starlark.String("b"))}, &s, AnySetType(reflect.TypeOf(map[string]bool(nil))))
+		require.NoError(t, err)
+		require.Equal(t, S{Set: map[string]bool{"a": true, "b": true}}, s)
+	})
+
+	t.Run("set of tuples into map", func(t *testing.T) {
+		var s S
+		err := FromStarlark(M{"Tup": set(tup(starlark.MakeInt(1)))}, &s, AnySetType(reflect.TypeOf(map[any]bool(nil))))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `Tup[0]: cannot convert Starlark tuple to Go type interface {}`)
+	})
+
+	t.Run("big int type", func(t *testing.T) {
+		var s S
+		err := FromStarlark(M{"Big": starlark.MakeUint64(math.MaxUint64)}, &s, AnyBigIntType(reflect.TypeOf(uint64(0))))
+		require.NoError(t, err)
+		require.Equal(t, S{Big: uint64(math.MaxUint64)}, s)
+	})
+
+	t.Run("big int type out of range", func(t *testing.T) {
+		var s S
+		err := FromStarlark(M{"Big": starlark.MakeBigInt(tooBig)}, &s, AnyBigIntType(reflect.TypeOf(uint64(0))))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `Big: cannot assign Starlark int to Go type uint64: value out of range`)
+	})
+}
+
+func TestFromStarlark_BigNum(t *testing.T) {
+	type S struct {
+		I    big.Int
+		Iptr *big.Int
+		F    big.Float
+		Fptr *big.Float
+		R    big.Rat
+		Rptr *big.Rat
+	}
+
+	cases := []struct {
+		name string
+		vals M
+		want string // fmt.Sprint of the resulting field
+		err  string
+	}{
+		{"int into big.Int", M{"I": starlark.MakeInt(-12)}, "-12", ``},
+		{"big int into big.Int", M{"I": starlark.MakeBigInt(tooBig)}, tooBig.String(), ``},
+		{"big int into *big.Int", M{"Iptr": starlark.MakeBigInt(tooBig)}, tooBig.String(), ``},
+		{"float into big.Int", M{"I": starlark.Float(1e20)}, "100000000000000000000", ``},
+		{"fractional float into big.Int", M{"I": starlark.Float(1.5)}, "", `I: cannot assign Starlark float to Go type big.Int: value cannot be exactly represented`},
+		{"inf into big.Int", M{"I": starlark.Float(math.Inf(1))}, "", `I: cannot assign Starlark float to Go type big.Int: value cannot be exactly represented`},
+		{"string into big.Int", M{"I": starlark.String("1")}, "", `I: cannot convert Starlark string to Go type big.Int`},
+		{"dict into *big.Int", M{"Iptr": dict(M{})}, "", `Iptr: cannot convert Starlark dict to Go type *big.Int`},
+		{"None into *big.Int", M{"Iptr": starlark.None}, "<nil>", ``},
+		{"None into big.Int", M{"I": starlark.None}, "", `I: cannot convert Starlark NoneType to Go type big.Int`},
+
+		{"int into big.Float", M{"F": starlark.MakeBigInt(tooBig)}, "1.8446744073709551616e+19", ``},
+		{"float into big.Float", M{"F": starlark.Float(1.5)}, "1.5", ``},
+		{"float into *big.Float", M{"Fptr": starlark.Float(-0.25)}, "-0.25", ``},
+		{"inf into big.Float", M{"F": starlark.Float(math.Inf(-1))}, "-Inf", ``},
+		{"nan into big.Float", M{"F": starlark.Float(math.NaN())}, "", `F: cannot assign Starlark float to Go type big.Float: value cannot be exactly represented`},
+
+		{"int into big.Rat", M{"R": starlark.MakeInt(3)}, "3/1", ``},
+		{"float into big.Rat", M{"R": starlark.Float(0.5)}, "1/2", ``},
+		{"float into *big.Rat", M{"Rptr": starlark.Float(0.25)}, "1/4", ``},
+		{"inf into big.Rat", M{"R": starlark.Float(math.Inf(1))}, "", `R: cannot assign Starlark float to Go type big.Rat: value cannot be exactly represented`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var s S
+			err := FromStarlark(c.vals, &s)
+			if c.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.err)
+				return
+			}
+			require.NoError(t, err)
+
+			var fld reflect.Value
+			for k := range c.vals {
+				fld = reflect.ValueOf(s).FieldByName(k)
+			}
+			if fld.Kind() != reflect.Pointer {
+				ptr := reflect.New(fld.Type())
+				ptr.Elem().Set(fld)
+				fld = ptr
+			}
+			require.Equal(t, c.want, fmt.Sprint(fld.Interface()))
+		})
+	}
+}
+
+func TestFromStarlark_Time(t *testing.T) {
+	type S struct {
+		T    time.Time
+		Tptr *time.Time
+		Tms  time.Time `starlark:"Tms,asint=ms"`
+		D    time.Duration
+		Dptr *time.Duration
+		Ds   time.Duration   `starlark:"Ds,asint=s"`
+		Dl   []time.Duration `starlark:"Dl,aslist,asint=ms"`
+	}
+
+	ts := time.Date(2023, 2, 5, 16, 5, 16, 500_000_000, time.UTC)
+
+	cases := []struct {
+		name string
+		vals M
+		want any // the resulting field, dereferenced
+		err  string
+	}{
+		{"time into time.Time", M{"T": startime.Time(ts)}, ts, ``},
+		{"time into *time.Time", M{"Tptr": startime.Time(ts)}, ts, ``},
+		{"string into time.Time", M{"T": starlark.String("2023-02-05T16:05:16.5Z")}, ts, ``},
+		{"int into time.Time", M{"T": starlark.MakeInt(1675613116)}, ts.Truncate(time.Second), ``},
+		{"int into time.Time ms", M{"Tms": starlark.MakeInt(1675613116500)}, ts, ``},
+		{"negative int into time.Time", M{"T": starlark.MakeInt(-1)}, time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC), ``},
+		{"big int into time.Time", M{"T": starlark.MakeBigInt(tooBig)}, nil, `T: cannot assign Starlark int to Go type time.Time: value out of range`},
+		{"invalid string into time.Time", M{"T": starlark.String("2023-02-05")}, nil, `T: cannot convert Starlark string to Go type time.Time: parsing time "2023-02-05"`},
+		{"float into time.Time", M{"T": starlark.Float(1.5)}, nil, `T: cannot convert Starlark float to Go type time.Time`},
+		{"duration into time.Time", M{"T": startime.Duration(1)}, nil, `T: cannot convert Starlark time.duration to Go type time.Time`},
+		{"None into *time.Time", M{"Tptr": starlark.None}, (*time.Time)(nil), ``},
+
+		{"duration into time.Duration", M{"D": startime.Duration(5 * time.Minute)}, 5 * time.Minute, ``},
+		{"duration into *time.Duration", M{"Dptr": startime.Duration(time.Hour)}, time.Hour, ``},
+		{"string into time.Duration", M{"D": starlark.String("5m")}, 5 * time.Minute, ``},
+		{"int into time.Duration", M{"D": starlark.MakeInt(1000)}, time.Microsecond, ``},
+		{"int into time.Duration s", M{"Ds": starlark.MakeInt(30)}, 30 * time.Second, ``},
+		{"ints into []time.Duration ms", M{"Dl": list(starlark.MakeInt(1), starlark.String("2s"))}, []time.Duration{time.Millisecond, 2 * time.Second}, ``},
+		{"int out of range into time.Duration s", M{"Ds": starlark.MakeInt64(math.MaxInt64 / 100)}, nil, `Ds: cannot assign Starlark int to Go type time.Duration: value out of range`},
+		{"invalid string into time.Duration", M{"D": starlark.String("5 minutes")}, nil, `D: cannot convert Starlark string to Go type time.Duration: time: unknown unit`},
+		{"time into time.Duration", M{"D": startime.Time(ts)}, nil, `D: cannot convert Starlark time.time to Go type time.Duration`},
+		{"None into time.Duration", M{"D": starlark.None}, nil, `D: cannot convert Starlark NoneType to Go type time.Duration`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var s S
+			err := FromStarlark(c.vals, &s)
+			if c.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.err)
+				return
+			}
+			require.NoError(t, err)
+
+			var fld reflect.Value
+			for k := range c.vals {
+				fld = reflect.ValueOf(s).FieldByName(k)
+			}
+			if fld.Kind() == reflect.Pointer && !fld.IsNil() {
+				fld = fld.Elem()
+			}
+			require.Equal(t, c.want, fld.Interface())
+		})
+	}
+}
+
+func TestFromStarlark_TimeInvalidUnit(t *testing.T) {
+	var s struct {
+		D time.Duration `starlark:"D,asint=sec"`
+		T time.Time     `starlark:"T,asint=days"`
+	}
+	err := FromStarlark(M{"D": starlark.MakeInt(5), "T": starlark.String("2023-02-05T16:05:16Z")}, &s)
+	require.EqualError(t, err, "D: invalid tag option asint=sec: invalid time unit \"sec\"\nT: invalid tag option asint=days: invalid time unit \"days\"")
+	var te *TagError
+	require.ErrorAs(t, err, &te)
+	require.Zero(t, s.D)
+	require.Zero(t, s.T)
+}
+
+func TestFromStarlark_ParseError(t *testing.T) {
+	type S struct {
+		D []time.Duration
+	}
+	var s S
+	err := FromStarlark(M{"D": list(starlark.String("1s"), starlark.String("x"))}, &s)
+	require.Error(t, err)
+
+	var perr *ParseError
+	require.ErrorAs(t, err, &perr)
+	require.Equal(t, "D[1]", perr.Path)
+	require.Equal(t, starlark.String("x"), perr.StarVal)
+	require.NotNil(t, errors.Unwrap(perr))
+}
+
+func TestFromStarlark_Unmarshalers(t *testing.T) {
+	type S struct {
+		Level  myLevel
+		LevelP *myLevel
+		Levels []myLevel
+		ID     myID
+		IDP    *myID
+		Prefix netip.Prefix
+	}
+
+	cases := []struct {
+		name string
+		vals M
+		want any // the resulting field, dereferenced
+		err  string
+	}{
+		{"string into text unmarshaler", M{"Level": starlark.String("warn")}, myLevel(2), ``},
+		{"bytes into text unmarshaler", M{"Level": starlark.Bytes("info")}, myLevel(1), ``},
+		{"int into text unmarshaler", M{"Level": starlark.MakeInt(1)}, myLevel(1), ``},
+		{"string into *text unmarshaler", M{"LevelP": starlark.String("info")}, myLevel(1), ``},
+		{"None into *text unmarshaler", M{"LevelP": starlark.None}, (*myLevel)(nil), ``},
+		{"strings into []text unmarshaler", M{"Levels": list(starlark.String("debug"), starlark.String("warn"))}, []myLevel{0, 2}, ``},
+		{"bytes into binary unmarshaler", M{"ID": starlark.Bytes("\x01\x02")}, myID{hi: 1, lo: 2}, ``},
+		{"string into binary unmarshaler", M{"ID": starlark.String("ab")}, myID{hi: 'a', lo: 'b'}, ``},
+		{"bytes into *binary unmarshaler", M{"IDP": starlark.Bytes("xy")}, myID{hi: 'x', lo: 'y'}, ``},
+		{"string into netip.Prefix", M{"Prefix": starlark.String("10.0.0.0/8")}, netip.MustParsePrefix("10.0.0.0/8"), ``},
+		{"invalid string into text unmarshaler", M{"Level": starlark.String("error")}, nil, `Level: cannot convert Starlark string to Go type starstruct.myLevel: unknown level: "error"`},
+		{"invalid bytes into binary unmarshaler", M{"IDP": starlark.Bytes("xyz")}, nil, `IDP: cannot convert Starlark bytes to Go type *starstruct.myID: invalid id length: 3`},
+		{"invalid string into netip.Prefix", M{"Prefix": starlark.String("10.0.0.0")}, nil, `Prefix: cannot convert Starlark string to Go type netip.Prefix: netip.ParsePrefix("10.0.0.0"): no '/'`},
+		{"list into binary unmarshaler", M{"ID": list()}, nil, `ID: cannot convert Starlark list to Go type starstruct.myID`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var s S
+			err := FromStarlark(c.vals, &s)
+			if c.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.err)
+				return
+			}
+			require.NoError(t, err)
+
+			var fld reflect.Value
+			for k := range c.vals {
+				fld = reflect.ValueOf(s).FieldByName(k)
+			}
+			if fld.Kind() == reflect.Pointer && !fld.IsNil() {
+				fld = fld.Elem()
+			}
+			require.Equal(t, c.want, fld.Interface())
+		})
+	}
+
+	t.Run("error keeps existing value", func(t *testing.T) {
+		s := S{Level: 1, Levels: []myLevel{2}}
+		err := FromStarlark(M{"Level": starlark.String("x"), "Levels": list(starlark.String("y"))}, &s)
+		require.Error(t, err)
+
+		var merr *MarshalerError
+		errs := err.(interface{ Unwrap() []error }).Unwrap()
+		require.Len(t, errs, 2)
+		require.ErrorAs(t, errs[0], &merr)
+		require.Equal(t, OpFromStarlark, merr.Op)
+		require.Equal(t, starlark.String("x"), merr.StarVal)
+		require.NotNil(t, errors.Unwrap(merr))
+		require.Equal(t, myLevel(1), s.Level)
+	})
+
+	t.Run("ignored", func(t *testing.T) {
+		var s S
+		err := FromStarlark(M{"Level": starlark.String("info")}, &s, IgnoreTextUnmarshalers())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `Level: cannot convert Starlark string to Go type starstruct.myLevel`)
+	})
+}
+
+func TestFromStarlark_StarlarkUnmarshaler(t *testing.T) {
+	type S struct {
+		P      myPoint
+		Pptr   *myPoint
+		Pnil   *myPoint
+		Points []myPoint
+	}
+
+	s := S{Pnil: &myPoint{1, 1}}
+	err := FromStarlark(M{
+		"P":      tup(starlark.MakeInt(1), starlark.MakeInt(2)),
+		"Pptr":   tup(starlark.MakeInt(3), starlark.MakeInt(4)),
+		"Pnil":   starlark.None,
+		"Points": list(tup(starlark.MakeInt(5), starlark.MakeInt(6)), starlark.String("x")),
+	}, &s)
+	require.Error(t, err)
+
+	var ce *CustomConvError
+	require.ErrorAs(t, err, &ce)
+	require.Equal(t, OpFromStarlark, ce.Op)
+	require.Equal(t, "Points[1]", ce.Path)
+	require.Equal(t, starlark.String("x"), ce.StarVal)
+	require.EqualError(t, ce, `Points[1]: custom converter error: want a tuple of 2 ints, got string`)
+
+	require.Equal(t, S{
+		P:      myPoint{1, 2},
+		Pptr:   &myPoint{3, 4},
+		Points: []myPoint{{5, 6}, {}},
+	}, s)
+}
+
+func TestFromStarlark_HasAttrs(t *testing.T) {
+	type Srv struct {
+		Addr string `starlark:"addr"`
+		Port int    `starlark:"port"`
+		TLS  bool
+	}
+	type S struct {
+		Server  Srv
+		SrvPtr  *Srv
+		Servers []Srv
+		Int     int
+		Map     map[string]int
+	}
+
+	strct := func(kvs ...any) starlark.Value {
+		sd := make(starlark.StringDict)
+		for i := 0; i < len(kvs); i += 2 {
+			sd[kvs[i].(string)] = kvs[i+1].(starlark.Value)
+		}
+		return starlarkstruct.FromStringDict(starlarkstruct.Default, sd)
+	}
+
+	cases := []struct {
+		name string
+		vals M
+		want S
+		err  string
+	}{
+		{"struct into struct", M{"Server": strct("addr", starlark.String("a"), "port", starlark.MakeInt(1), "tls", starlark.True)}, S{Server: Srv{Addr: "a", Port: 1, TLS: true}}, ``},
+		{"struct into *struct", M{"SrvPtr": strct("addr", starlark.String("b"))}, S{SrvPtr: &Srv{Addr: "b"}}, ``},
+		{"empty struct into *struct", M{"SrvPtr": strct()}, S{}, ``},
+		{"structs into []struct", M{"Servers": list(strct("port", starlark.MakeInt(2)), dict(M{"port": starlark.MakeInt(3)}))}, S{Servers: []Srv{{Port: 2}, {Port: 3}}}, ``},
+		{"module into struct", M{"Server": &starlarkstruct.Module{Name: "srv", Members: starlark.StringDict{"addr": starlark.String("c")}}}, S{Server: Srv{Addr: "c"}}, ``},
+		{"invalid field in struct", M{"Server": strct("port", starlark.String("x"))}, S{}, `Server.Port: cannot convert Starlark string to Go type int`},
+		{"struct into int", M{"Int": strct()}, S{}, `Int: cannot convert Starlark struct to Go type int`},
+		{"struct into map", M{"Map": strct()}, S{}, `Map: cannot convert Starlark struct to Go type map[string]int`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var s S
+			err := FromStarlark(c.vals, &s)
+			if c.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.want, s)
+		})
+	}
+}
+
+func TestFromStarlark_Iterables(t *testing.T) {
+	type Inner struct {
+		A int
+		B string
+	}
+	type S struct {
+		Ints  []int
+		Arr   [3]int
+		Inner Inner
+		Map   map[string]int
+		Any   any
+	}
+
+	rng, err := starlark.Call(&starlark.Thread{}, starlark.Universe["range"], starlark.Tuple{starlark.MakeInt(3)}, nil)
+	require.NoError(t, err)
+	mapping := myMapping{dict(M{"A": starlark.MakeInt(1), "B": starlark.String("b")})}
+
+	cases := []struct {
+		name string
+		vals M
+		want S
+		err  string
+	}{
+		{"range into slice", M{"Ints": rng}, S{Ints: []int{0, 1, 2}}, ``},
+		{"range into array", M{"Arr": rng}, S{Arr: [3]int{0, 1, 2}}, ``},
+		{"iterable into slice", M{"Ints": myIterable{starlark.MakeInt(4), starlark.MakeInt(5)}}, S{Ints: []int{4, 5}}, ``},
+		{"empty iterable into slice", M{"Ints": myIterable{}}, S{Ints: []int{}}, ``},
+		{"iterable into array", M{"Arr": myIterable{starlark.MakeInt(4), starlark.MakeInt(5), starlark.MakeInt(6)}}, S{Arr: [3]int{4, 5, 6}}, ``},
+		{"iterable into array length mismatch", M{"Arr": myIterable{starlark.MakeInt(4)}}, S{}, `Arr: cannot assign Starlark my_iterable to Go type [3]int: expected length 3, got 1`},
+		{"iterable invalid element", M{"Ints": myIterable{starlark.String("x")}}, S{}, `Ints[0]: cannot convert Starlark string to Go type int`},
+		{"iterable into struct", M{"Inner": myIterable{}}, S{}, `Inner: cannot convert Starlark my_iterable to Go type starstruct.Inner`},
+		{"iterable into any", M{"Any": myIterable{starlark.MakeInt(1)}}, S{Any: []any{int64(1)}}, ``},
+		{"mapping into struct", M{"Inner": mapping}, S{Inner: Inner{A: 1, B: "b"}}, ``},
+		{"mapping into map", M{"Map": myMapping{dict(M{"x": starlark.MakeInt(1)})}}, S{Map: map[string]int{"x": 1}}, ``},
+		{"mapping into map invalid value", M{"Map": myMapping{dict(M{"x": starlark.String("1")})}}, S{Map: map[string]int{}}, `Map["x"]: cannot convert Starlark string to Go type int`},
+		{"mapping into slice", M{"Ints": mapping}, S{}, `Ints: cannot convert Starlark my_mapping to Go type []int`},
+		{"mapping into any", M{"Any": myMapping{dict(M{"x": starlark.MakeInt(1)})}}, S{Any: map[string]any{"x": int64(1)}}, ``},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var s S
+			err := FromStarlark(c.vals, &s)
+			if c.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.want, s)
+		})
+	}
+}
+
+func TestFromStarlark_BigFloatPrecision(t *testing.T) {
+	type S struct {
+		F *big.Float
+	}
+	s := S{F: new(big.Float).SetPrec(8).SetInt64(3)}
+	err := FromStarlark(M{"F": starlark.MakeInt(257)}, &s)
+	require.Error(t, err)
+	var ne *NumberError
+	require.ErrorAs(t, err, &ne)
+	require.Equal(t, NumCannotExactlyRepresent, ne.Reason)
+	require.Equal(t, OpFromStarlark, ne.Op)
+
+	// the value is left unmodified if it cannot be exactly represented
+	require.Equal(t, "3", s.F.String())
+	require.Equal(t, uint(8), s.F.Prec())
+
+	err = FromStarlark(M{"F": starlark.Float(0.1)}, &s)
+	require.ErrorAs(t, err, &ne)
+	require.Equal(t, "3", s.F.String())
+
+	// an exact value is set with the same precision
+	require.NoError(t, FromStarlark(M{"F": starlark.MakeInt(256)}, &s))
+	require.Equal(t, "256", s.F.String())
+	require.Equal(t, uint(8), s.F.Prec())
+}
+
 func TestFromStarlark_InvalidDestination(t *testing.T) {
 	var s string
 
@@ -541,6 +1135,7 @@ func TestFromStarlark_CustomConverter(t *testing.T) {
 		},
 		T: &T{
 			T1: date(2022, 1, 2),
+			T2: tptr(time.Unix(1675613116, 0).UTC()),
 			T3: time.Unix(1672578000, 0),
 			S:  "a",
 		},
@@ -548,13 +1143,10 @@ func TestFromStarlark_CustomConverter(t *testing.T) {
 	}, s)
 
 	var convErr *CustomConvError
-	var typeErr *TypeError
 	errs := err.(interface{ Unwrap() []error }).Unwrap()
-	require.Len(t, errs, 3)
-	require.ErrorAs(t, errs[0], &typeErr)
-	require.Equal(t, "T.T2", typeErr.Path)
-	require.ErrorAs(t, errs[1], &convErr)
+	require.Len(t, errs, 2)
+	require.ErrorAs(t, errs[0], &convErr)
 	require.Equal(t, "T.T4", convErr.Path)
-	require.ErrorAs(t, errs[2], &convErr)
+	require.ErrorAs(t, errs[1], &convErr)
 	require.Equal(t, "N.D1", convErr.Path)
 }
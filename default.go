@@ -0,0 +1,108 @@
+package starstruct
+
+import (
+	"errors"
+	"sync"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// NoneAsDefault sets the decoding of None into a field that has a default
+// value (set with the default struct tag option, e.g.
+// `starlark:"port,default=80"`) to decode that default value instead, so that
+// None resets the field to its default. By default, None is decoded as any
+// other value, and the default value is only decoded if the key is missing.
+func NoneAsDefault() FromOption {
+	return func(d *decoder) {
+		d.noneAsDefault = true
+	}
+}
+
+// returns the starlark value to decode into the field at path with the field
+// options fopts, given the value v provided for that field, if ok is true.
+// That is the default value of the field if no value is provided, or if v is
+// None and the NoneAsDefault option is set, and v otherwise. It returns false
+// if there is no value nor default value to decode. An invalid default value
+// is recorded as a TagError, and the field is then considered to have no
+// default value.
+func (d *decoder) withDefault(path string, v starlark.Value, ok bool, fopts []fieldOpt) (starlark.Value, bool) {
+	if ok && (v != starlark.None || !d.noneAsDefault) {
+		return v, true
+	}
+	if def, hasDef := findFieldOpt(fopts, "default"); hasDef {
+		defVal, err := defaultValue(def.arg)
+		if err == nil {
+			return defVal, true
+		}
+		d.recordErr(&TagError{Path: path, Option: def.String(), Err: err})
+	}
+	return v, ok
+}
+
+// cache of the parsed default values, which are frozen.
+var defaults sync.Map
+
+// returns the starlark value of the default literal lit, or an error if lit
+// is not a valid literal.
+func defaultValue(lit string) (starlark.Value, error) {
+	if v, ok := defaults.Load(lit); ok {
+		return v.(starlark.Value), nil
+	}
+	v, err := evalLiteral(lit)
+	if err != nil {
+		return nil, err
+	}
+	v.Freeze()
+	defaults.Store(lit, v)
+	return v, nil
+}
+
+// evaluates the starlark literal lit, which may be a number, a string or
+// bytes literal, None, True, False, or a list, tuple or dict of literals.
+func evalLiteral(lit string) (starlark.Value, error) {
+	expr, err := syntax.ParseExpr("default", lit, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !isLiteral(expr) {
+		return nil, errors.New("not a literal")
+	}
+	return starlark.EvalExpr(&starlark.Thread{Name: "default"}, expr, nil)
+}
+
+func isLiteral(expr syntax.Expr) bool {
+	switch expr := expr.(type) {
+	case *syntax.Literal:
+		return true
+	case *syntax.Ident:
+		return expr.Name == "None" || expr.Name == "True" || expr.Name == "False"
+	case *syntax.ParenExpr:
+		return isLiteral(expr.X)
+	case *syntax.UnaryExpr:
+		_, ok := expr.X.(*syntax.Literal)
+		return ok && (expr.Op == syntax.MINUS || expr.Op == syntax.PLUS)
+	case *syntax.ListExpr:
+		return allLiterals(expr.List)
+	case *syntax.TupleExpr:
+		return allLiterals(expr.List)
+	case *syntax.DictExpr:
+		for _, e := range expr.List {
+			entry := e.(*syntax.DictEntry)
+			if !isLiteral(entry.Key) || !isLiteral(entry.Value) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func allLiterals(exprs []syntax.Expr) bool {
+	for _, e := range exprs {
+		if !isLiteral(e) {
+			return false
+		}
+	}
+	return true
+}
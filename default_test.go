@@ -0,0 +1,176 @@
+package starstruct
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.starlark.net/starlark"
+)
+
+type defaultServer struct {
+	Name    string            `starlark:"name,required"`
+	Port    int               `starlark:"port,min=1,default=80"`
+	Tags    []string          `starlark:"tags,default=['a', 'b']"`
+	Labels  map[string]int    `starlark:"labels,default={'x': 1, 'y': -2}"`
+	Timeout time.Duration     `starlark:"timeout,asint=s,default=30"`
+	Ratio   *float64          `starlark:"ratio,default=0.5"`
+	Raw     starlark.Value    `starlark:"raw,default=(1, None, True, b'z')"`
+	Extra   map[string]string `starlark:"extra"`
+}
+
+func TestFromStarlark_Defaults(t *testing.T) {
+	ratio := 0.5
+	var out defaultServer
+	err := FromStarlark(M{"name": starlark.String("n")}, &out)
+	require.NoError(t, err)
+
+	raw := starlark.Tuple{starlark.MakeInt(1), starlark.None, starlark.True, starlark.Bytes("z")}
+	require.Equal(t, defaultServer{
+		Name:    "n",
+		Port:    80,
+		Tags:    []string{"a", "b"},
+		Labels:  map[string]int{"x": 1, "y": -2},
+		Timeout: 30 * time.Second,
+		Ratio:   &ratio,
+		Raw:     raw,
+	}, out)
+
+	// provided values take precedence, and None is decoded as-is by default
+	out = defaultServer{}
+	err = FromStarlark(M{
+		"name":  starlark.String("n"),
+		"port":  starlark.MakeInt(8080),
+		"tags":  starlark.None,
+		"ratio": starlark.None,
+	}, &out)
+	require.NoError(t, err)
+	require.Equal(t, 8080, out.Port)
+	require.Nil(t, out.Tags)
+	require.Nil(t, out.Ratio)
+}
+
+func TestFromStarlark_NoneAsDefault(t *testing.T) {
+	ratio := 1.5
+	out := defaultServer{Ratio: &ratio, Extra: map[string]string{"a": "b"}}
+	err := FromStarlark(M{
+		"name":  starlark.String("n"),
+		"port":  starlark.None,
+		"ratio": starlark.None,
+		"extra": starlark.None,
+	}, &out, NoneAsDefault())
+	require.NoError(t, err)
+	require.Equal(t, 80, out.Port)
+	require.Equal(t, 0.5, *out.Ratio)
+	// no default value, None is decoded as usual
+	require.Nil(t, out.Extra)
+}
+
+func TestFromStarlark_Required(t *testing.T) {
+	var out struct {
+		Server defaultServer `starlark:"server"`
+		Count  int           `starlark:",required"`
+		Nested struct {
+			ID string `starlark:"id,required"`
+		} `starlark:"nested"`
+	}
+	err := FromStarlark(M{
+		"server": dict(M{}),
+		"nested": dict(M{}),
+	}, &out)
+	require.EqualError(t, err, `Server.Name: missing required key "name"`+"\n"+
+		`Count: missing required key "Count"`+"\n"+
+		`Nested.ID: missing required key "id"`)
+
+	var mfe *MissingFieldError
+	require.ErrorAs(t, err, &mfe)
+	require.Equal(t, "Server.Name", mfe.Path)
+	require.Equal(t, "name", mfe.Key)
+
+	// a None value is not missing, and the lowercase name is accepted
+	err = FromStarlark(M{
+		"server": dict(M{"name": starlark.String("n")}),
+		"count":  starlark.MakeInt(1),
+		"nested": dict(M{"id": starlark.None}),
+	}, &out)
+	require.EqualError(t, err, "Nested.ID: cannot convert Starlark NoneType to Go type string")
+	require.Equal(t, 1, out.Count)
+	require.Equal(t, 80, out.Server.Port)
+}
+
+func TestFromStarlark_DefaultAndRegexp(t *testing.T) {
+	var out struct {
+		A string `starlark:"a,nonempty,default='a,b',regexp=^[a-z]{1,2},[a-z]$"`
+		B string `starlark:"b,regexp=^[a-z]{1,2},[a-z]$,default='c,d'"`
+	}
+	require.NoError(t, FromStarlark(nil, &out))
+	require.Equal(t, "a,b", out.A)
+	require.Equal(t, "c,d", out.B)
+
+	err := FromStarlark(M{"a": starlark.String("a"), "b": starlark.String("abc,d")}, &out)
+	require.EqualError(t, err, `A: value "a" does not satisfy regexp=^[a-z]{1,2},[a-z]$`+"\n"+
+		`B: value "abc,d" does not satisfy regexp=^[a-z]{1,2},[a-z]$`)
+}
+
+func TestFromStarlark_InvalidDefaults(t *testing.T) {
+	t.Run("not a literal", func(t *testing.T) {
+		var out struct {
+			A int `starlark:"a,default=len('a')"`
+			B int `starlark:"b"`
+		}
+		err := FromStarlark(M{"b": starlark.MakeInt(1)}, &out)
+		require.EqualError(t, err, "A: invalid tag option default=len('a'): not a literal")
+		var te *TagError
+		require.ErrorAs(t, err, &te)
+		require.Equal(t, 0, out.A)
+		require.Equal(t, 1, out.B)
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		var out struct {
+			A int `starlark:"a,default=[1,"`
+		}
+		err := FromStarlark(nil, &out)
+		var te *TagError
+		require.ErrorAs(t, err, &te)
+		require.Equal(t, "default=[1,", te.Option)
+	})
+
+	t.Run("unpack args", func(t *testing.T) {
+		var args struct {
+			A int `starlark:"a,default=x"`
+		}
+		err := UnpackArgs("fn", nil, nil, &args)
+		require.EqualError(t, err, "fn: for parameter a: A: invalid tag option default=x: not a literal")
+	})
+
+	t.Run("checked on creation", func(t *testing.T) {
+		type bad struct {
+			A int `starlark:"a,default=len('a')"`
+		}
+		require.PanicsWithValue(t, "invalid struct tag in type starstruct.bad: A: invalid tag option default=len('a'): not a literal", func() {
+			Constructor(reflect.TypeOf(bad{}))
+		})
+	})
+
+	t.Run("not convertible", func(t *testing.T) {
+		// a default value that cannot be converted is reported as usual
+		var out struct {
+			A int `starlark:"a,default='x'"`
+		}
+		err := FromStarlark(nil, &out)
+		require.EqualError(t, err, "A: cannot convert Starlark string to Go type int")
+	})
+}
+
+func TestUnpackArgs_Defaults(t *testing.T) {
+	var args struct {
+		Addr string `starlark:"addr,required"`
+		Port int    `starlark:"port,default=80"`
+	}
+	err := UnpackArgs("fn", starlark.Tuple{starlark.String("a")}, nil, &args)
+	require.NoError(t, err)
+	require.Equal(t, "a", args.Addr)
+	require.Equal(t, 80, args.Port)
+}
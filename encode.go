@@ -3,10 +3,13 @@ package starstruct
 import (
 	"errors"
 	"fmt"
+	"math/big"
 	"reflect"
+	"sort"
 	"strings"
 
 	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
 )
 
 // ToOption is the type of the encoding options that can be provided to the
@@ -42,6 +45,61 @@ func CustomToConverter(fn func(path string, goVal reflect.Value, tagOpts []strin
 	}
 }
 
+// UnsupportedPolicy defines how ToStarlark handles Go values that cannot be
+// converted to a starlark value.
+type UnsupportedPolicy byte
+
+// List of policies for unsupported Go values.
+const (
+	// UnsupportedError records a TypeError for the unsupported value. This is
+	// the default policy.
+	UnsupportedError UnsupportedPolicy = iota
+	// UnsupportedSkip silently skips the unsupported value: a struct field is
+	// not set in the destination dictionary, and an element of a slice, array
+	// or map is not added to the starlark container.
+	UnsupportedSkip
+	// UnsupportedStringify converts the unsupported value to a starlark String
+	// if it implements the error or fmt.Stringer interfaces (in that order of
+	// precedence), and records a TypeError otherwise.
+	UnsupportedStringify
+)
+
+// OnUnsupported sets the policy to apply when a Go value cannot be converted
+// to a starlark value. The default is UnsupportedError.
+func OnUnsupported(policy UnsupportedPolicy) ToOption {
+	return func(e *encoder) {
+		e.unsupported = policy
+	}
+}
+
+// StructEncoding defines how ToStarlark converts Go structs to starlark
+// values.
+type StructEncoding byte
+
+// List of encodings for Go structs.
+const (
+	// StructAsDict converts Go structs to a starlark Dict, with a key for each
+	// field. This is the default encoding.
+	StructAsDict StructEncoding = iota
+	// StructAsStruct converts Go structs to a starlarkstruct.Struct (as created
+	// by the struct builtin), so that fields are accessed as attributes, e.g.
+	// server.addr.
+	StructAsStruct
+	// StructAsModule converts Go structs to a starlarkstruct.Module, which is
+	// typically used for top-level namespaces. The name of the module is the
+	// Go struct path of the value.
+	StructAsModule
+)
+
+// EncodeStructsAs sets the encoding used to convert Go structs to starlark
+// values. The default is StructAsDict. It can be overridden for a specific
+// struct field with the asdict, asstruct and asmodule tag options.
+func EncodeStructsAs(enc StructEncoding) ToOption {
+	return func(e *encoder) {
+		e.structs = enc
+	}
+}
+
 // ToStarlark converts the values from the Go struct to corresponding Starlark
 // values stored into a destination Starlark string dictionary. Existing values
 // in dst, if any, are left untouched unless the Go struct conversion
@@ -51,16 +109,68 @@ func CustomToConverter(fn func(path string, goVal reflect.Value, tagOpts []strin
 // can also be a pointer to that type:
 //   - nil (pointer, slice or map) => NoneType
 //   - bool => Bool
-//   - []byte => Bytes
+//   - []byte or [N]byte => Bytes
 //   - string => String
 //   - float32 or float64 => Float
 //   - int, uint, and any sized (u)int => Int
-//   - struct => Dict
-//   - slice of any supported Go type => List
+//   - big.Int => Int
+//   - big.Float or big.Rat => Float, if it can be exactly represented
+//   - time.Time => lib/time Time (go.starlark.net/lib/time)
+//   - time.Duration => lib/time Duration
+//   - struct => Dict (see EncodeStructsAs)
+//   - slice or array of any supported Go type => List
 //   - map[T]bool => Set
+//   - map[K]T where K is any supported key type and T is any supported Go
+//     type => Dict
+//   - func => Builtin (see Func), named after the Go struct path of the value
+//   - nil interface => NoneType
+//
+// A Go type that implements Marshaler (with a value or a pointer receiver) is
+// converted by calling its MarshalStarlark method, which takes precedence over
+// all other conversion rules. An error returned by the method is recorded as a
+// CustomConvError.
+//
+// A Go type that implements encoding.TextMarshaler is converted to a String
+// by calling MarshalText, and one that implements encoding.BinaryMarshaler
+// (but not encoding.TextMarshaler) is converted to Bytes by calling
+// MarshalBinary. An error returned by the marshaler is recorded as a
+// MarshalerError. This takes precedence over the conversion based on the
+// kind of the Go type, except for time and arbitrary-precision numbers, and
+// can be disabled with the IgnoreTextMarshalers option.
 //
 // In addition to those conversions, if the Go type is starlark.Value (or a
-// pointer to that type), then the starlark value is transferred as-is.
+// pointer to that type), then the starlark value is transferred as-is. If the
+// Go type is any other interface (or a pointer to an interface), then its
+// dynamic value is converted, following the same rules (and transferred as-is
+// if it is a starlark value). A nil interface is converted to None. If the
+// interface is registered as a discriminated union, the discriminator key is
+// added to the resulting Dict (see UnionRegistry and UnionToRegistry).
+//
+// Before a struct is encoded, its BeforeEncode method is called if it
+// implements the BeforeEncoder interface. If the struct is not addressable
+// (e.g. if a struct, instead of a pointer to a struct, is provided to
+// ToStarlark), the method is called on a copy, which is then encoded. An
+// error returned by the method is recorded as a HookError and the struct is
+// still encoded.
+//
+// The validation constraints set in the struct tags (see FromStarlark) are
+// ignored, unless the ValidateConstraints option is set.
+//
+// The starlark key of a field is the name in its starlark struct tag or, if
+// there is none, the name of the field. A NamingStrategy can be set with the
+// FieldNamingTo option to convert the name of the field, e.g. to max_conns
+// for a MaxConns field with SnakeCaseNaming.
+//
+// The entries of a remain field (see FromStarlark) are set as if they were
+// fields of the struct, after the other fields and in order of their sorted
+// keys. An entry with a key that matches another field of the struct is not
+// set, and a KeyConflictError is recorded.
+//
+// The methods of a struct are not converted, unless they are exposed as
+// starlark builtins with a Methods field or the ExposeMethods option.
+//
+// Go values that cannot be converted are handled according to the
+// UnsupportedPolicy (see OnUnsupported), by default a TypeError is recorded.
 //
 // Additional conversions can be supported via a custom converter (see
 // CustomToConverter).
@@ -69,17 +179,53 @@ func CustomToConverter(fn func(path string, goVal reflect.Value, tagOpts []strin
 // naming of the starlark variable, a comma-separated argument can be provided
 // to control the target encoding. The following arguments are supported:
 //   - For string fields, `starlark:"name,asbytes"` to convert to Bytes
-//   - For []byte fields, `starlark:"name,asstring"` to convert to String
-//   - For []byte ([]uint8) fields, `starlark:"name,aslist"` to convert to List
-//     (of Int)
-//   - For slices (including []byte), `starlark:"name,astuple"` to convert to
-//     Tuple
-//   - For slices (including []byte), `starlark:"name,asset"` to convert to Set
+//   - For big.Int fields, `starlark:"name,asfloat"` to convert to Float, if it
+//     can be exactly represented
+//   - For big.Float and big.Rat fields, `starlark:"name,asint"` to convert to
+//     Int, if it has no fractional part
+//   - For time.Time fields, `starlark:"name,asstring"` to convert to an RFC
+//     3339 String, and `starlark:"name,asint"` to convert to an Int number of
+//     seconds since the Unix epoch
+//   - For time.Duration fields, `starlark:"name,asstring"` to convert to a
+//     String such as "5m0s", and `starlark:"name,asint"` to convert to an Int
+//     number of nanoseconds
+//   - For time fields converted to Int, a unit can be specified with
+//     `starlark:"name,asint=ms"`, one of "ns", "us" (or "µs"), "ms", "s", "m"
+//     or "h". The time value must be an exact multiple of that unit, and an
+//     invalid unit is recorded as a TagError.
+//   - For []byte and [N]byte fields, `starlark:"name,asstring"` to convert to
+//     String
+//   - For []byte ([]uint8) and [N]byte fields, `starlark:"name,aslist"` to
+//     convert to List (of Int)
+//   - For slices and arrays (including of bytes), `starlark:"name,astuple"` to
+//     convert to Tuple
+//   - For slices and arrays (including of bytes), `starlark:"name,asset"` to
+//     convert to Set
+//   - For map[T]bool fields, `starlark:"name,asdict"` to convert to Dict
+//     (instead of Set)
+//   - For struct fields, `starlark:"name,asstruct"` to convert to a
+//     starlarkstruct.Struct, `starlark:"name,asmodule"` to convert to a
+//     starlarkstruct.Module and `starlark:"name,asdict"` to convert to Dict,
+//     regardless of the EncodeStructsAs option
 //
 // Any level of conversion arguments can be provided, to support for nested
 // conversions, e.g. this would convert to a Set of Tuples of Bytes:
 //   - [][]string `starlark:"name,asset,astuple,asbytes"`
 //
+// For maps converted to a Dict, the conversion arguments that follow the one
+// for the map itself apply to the map's values, e.g. this would convert to a
+// Dict of Tuples:
+//   - map[string][]int `starlark:"name,,astuple"`
+//
+// The supported Go map key types for conversion to a Dict or a Set are bool,
+// string, float and (u)int types, as well as arrays and structs (with only
+// exported fields) of those types, recursively. Arrays and structs are
+// converted to a Tuple of their elements or fields (except byte arrays, which
+// are converted to Bytes), so that they are hashable, e.g. a Go map key
+// struct{ Port int; Proto string }{80, "tcp"} is converted to the key
+// (80, "tcp"). The entries of a map converted to a Dict are inserted in order
+// of their sorted keys, so that the resulting Dict is deterministic.
+//
 // Embedded fields in structs are supported as follows:
 //   - The field must be exported
 //   - The type of the field must be a struct or a pointer to a struct
@@ -120,9 +266,17 @@ func ToStarlark(vals any, dst starlark.StringDict, opts ...ToOption) error {
 }
 
 type encoder struct {
-	errs    []error
-	maxErrs int
-	custom  func(string, reflect.Value, []string) (starlark.Value, error)
+	errs        []error
+	maxErrs     int
+	custom      func(string, reflect.Value, []string) (starlark.Value, error)
+	unsupported UnsupportedPolicy
+	unions      *UnionRegistry
+	structs     StructEncoding
+	methods     map[reflect.Type][]string
+	validate    bool
+	naming      NamingStrategy
+
+	ignoreMarshalers bool
 }
 
 func (e *encoder) encode(strct reflect.Value, sdict starlark.StringDict) (err error) {
@@ -136,18 +290,33 @@ func (e *encoder) encode(strct reflect.Value, sdict starlark.StringDict) (err er
 		}
 	}()
 
-	e.walkStructEncode("", strct, stringDictValue{sdict})
+	strct = e.beforeEncode("", strct)
+	e.encodeStruct("", strct, stringDictValue{sdict})
 	err = errors.Join(e.errs...)
 	return
 }
 
+// encodes the fields of the struct strct in dst, followed by the entries of
+// its remain field, if any.
+func (e *encoder) encodeStruct(path string, strct reflect.Value, dst dictGetSetter) {
+	e.walkStructEncode(path, strct, dst)
+	r, ok, err := remainField(path, strct)
+	if err != nil {
+		e.recordErr(err)
+	}
+	if ok {
+		e.spreadRemain(r, strct, dst)
+	}
+}
+
 func (e *encoder) walkStructEncode(path string, strct reflect.Value, dst dictGetSetter) {
 	strctTyp := strct.Type()
 	count := strctTyp.NumField()
 	for i := 0; i < count; i++ {
 		fldTyp := strctTyp.Field(i)
 		nm, rawOpts, _ := strings.Cut(fldTyp.Tag.Get("starlark"), ",")
-		if !fldTyp.IsExported() || nm == "-" {
+		if !fldTyp.IsExported() || nm == "-" || isRemainTag(rawOpts) {
+			// the remain field is encoded once all fields are
 			continue
 		}
 
@@ -160,9 +329,10 @@ func (e *encoder) walkStructEncode(path string, strct reflect.Value, dst dictGet
 		}
 		fld := strct.Field(i)
 
-		// use the field name as target starlark name, except if the field is an
-		// embedded anonymous struct - in this case we will walk this embedded
-		// struct as if the fields were in the current struct.
+		// use the field name (or its key according to the naming strategy) as
+		// target starlark name, except if the field is an embedded anonymous
+		// struct - in this case we will walk this embedded struct as if the
+		// fields were in the current struct.
 		if nm == "" {
 			if fldTyp.Anonymous {
 				if !isStructOrPtrType(fldTyp.Type) {
@@ -172,21 +342,26 @@ func (e *encoder) walkStructEncode(path string, strct reflect.Value, dst dictGet
 				e.walkStructEncode(path, fld, dst)
 				continue
 			}
-			nm = fldTyp.Name
+			nm, _ = fieldKey(e.naming, fldTyp.Name)
 		}
 
-		var opts []string
-		if rawOpts != "" {
-			opts = strings.Split(rawOpts, ",")
+		opts, fopts := parseTagOpts(rawOpts)
+		if e.validate {
+			e.validateField(path, fld, fopts)
 		}
 		e.toStarlarkValue(path, nm, fld, dst, opts)
 	}
+	e.exposeMethods(path, strct, dst)
 }
 
 func (e *encoder) toStarlarkValue(path, dstName string, goVal reflect.Value, dst dictGetSetter, opts tagOpt) {
 	key := starlark.String(dstName)
 
 	sval := e.convertGoValue(path, goVal, opts)
+	if sval == nil {
+		// unsupported value, skipped
+		return
+	}
 	if err := dst.SetKey(key, sval); err != nil {
 		// don't think this error can happen (key is always a string, create set is
 		// never immutable)
@@ -194,6 +369,8 @@ func (e *encoder) toStarlarkValue(path, dstName string, goVal reflect.Value, dst
 	}
 }
 
+// converts the Go value goVal to a starlark value. It returns nil if the value
+// is not supported and the UnsupportedSkip policy is set.
 func (e *encoder) convertGoValue(path string, goVal reflect.Value, opts tagOpt) starlark.Value {
 	if fn := e.custom; fn != nil {
 		starVal, err := fn(path, goVal, opts)
@@ -214,9 +391,8 @@ func (e *encoder) convertGoValue(path string, goVal reflect.Value, opts tagOpt)
 		isNil = goVal.IsNil()
 		goVal = goVal.Elem()
 	}
-	// map and slice can also be nil, and starlark.Value interface
-	if goVal.Kind() == reflect.Map || goVal.Kind() == reflect.Slice ||
-		(goVal.Kind() == reflect.Interface && goVal.Type() == starlarkValueType) {
+	// map, slice and interface can also be nil
+	if goVal.Kind() == reflect.Map || goVal.Kind() == reflect.Slice || goVal.Kind() == reflect.Interface {
 		isNil = goVal.IsNil()
 	}
 
@@ -226,6 +402,30 @@ func (e *encoder) convertGoValue(path string, goVal reflect.Value, opts tagOpt)
 		return starlark.None
 	case goVal.Type() == starlarkValueType:
 		return goVal.Interface().(starlark.Value)
+	case goVal.Kind() == reflect.Interface:
+		// transfer a starlark value as-is, otherwise convert the dynamic value of
+		// the interface.
+		if sv, ok := goVal.Interface().(starlark.Value); ok {
+			return sv
+		}
+		sval := e.convertGoValue(path, goVal.Elem(), opts)
+		if u := e.unions.lookup(goVal.Type()); u != nil && sval != nil {
+			sval = e.unionValue(path, u, goVal.Elem(), sval)
+		}
+		return sval
+	case isStarlarkMarshalerType(goVal.Type()):
+		return e.convertStarlarkMarshaler(path, goVal)
+	case goVal.Type() == timeType:
+		return e.convertTime(path, goVal, curOpt)
+	case goVal.Type() == durationType:
+		return e.convertDuration(path, goVal, curOpt)
+	case !e.ignoreMarshalers && !isBigNumType(goVal.Type()) && isMarshalerType(goVal.Type()):
+		return e.convertMarshaler(path, goVal)
+	case goVal.Kind() == reflect.Func:
+		if goVal.IsNil() {
+			return starlark.None
+		}
+		return newBuiltin(path, goVal, &decoder{unions: e.unions}, e)
 	case goVal.Kind() == reflect.Bool:
 		return starlark.Bool(goVal.Bool())
 	case goVal.Kind() == reflect.Float32 || goVal.Kind() == reflect.Float64:
@@ -241,45 +441,70 @@ func (e *encoder) convertGoValue(path string, goVal reflect.Value, opts tagOpt)
 		}
 		return starlark.String(goVal.String())
 
-	case isByteSliceType(goVal.Type()) && curOpt != "aslist" && curOpt != "astuple" && curOpt != "asset":
+	case (isByteSliceType(goVal.Type()) || isByteArrayType(goVal.Type())) && curOpt != "aslist" && curOpt != "astuple" && curOpt != "asset":
 		if curOpt == "asstring" {
-			return starlark.String(goVal.Bytes())
+			return starlark.String(bytesOf(goVal))
 		}
-		return starlark.Bytes(goVal.Bytes())
+		return starlark.Bytes(bytesOf(goVal))
 
-	case goVal.Kind() == reflect.Slice && curOpt != "astuple" && curOpt != "asset":
+	case isSliceOrArrayType(goVal.Type()) && curOpt != "astuple" && curOpt != "asset":
 		n := goVal.Len()
-		listVals := make([]starlark.Value, n)
+		listVals := make([]starlark.Value, 0, n)
 		for i := 0; i < n; i++ {
 			v := goVal.Index(i)
-			sval := e.convertGoValue(fmt.Sprintf("%s[%d]", path, i), v, opts.shift())
-			listVals[i] = sval
+			if sval := e.convertGoValue(fmt.Sprintf("%s[%d]", path, i), v, opts.shift()); sval != nil {
+				listVals = append(listVals, sval)
+			}
 		}
 		return starlark.NewList(listVals)
 
-	case goVal.Kind() == reflect.Slice && curOpt == "astuple":
+	case isSliceOrArrayType(goVal.Type()) && curOpt == "astuple":
 		n := goVal.Len()
-		tupVals := make([]starlark.Value, n)
+		tupVals := make([]starlark.Value, 0, n)
 		for i := 0; i < n; i++ {
 			v := goVal.Index(i)
-			sval := e.convertGoValue(fmt.Sprintf("%s[%d]", path, i), v, opts.shift())
-			tupVals[i] = sval
+			if sval := e.convertGoValue(fmt.Sprintf("%s[%d]", path, i), v, opts.shift()); sval != nil {
+				tupVals = append(tupVals, sval)
+			}
 		}
 		return starlark.Tuple(tupVals)
 
-	case goVal.Kind() == reflect.Slice && curOpt == "asset":
+	case isSliceOrArrayType(goVal.Type()) && curOpt == "asset":
 		n := goVal.Len()
 		set := starlark.NewSet(n)
 		for i := 0; i < n; i++ {
 			v := goVal.Index(i)
 			path := fmt.Sprintf("%s[%d]", path, i)
 			sval := e.convertGoValue(path, v, opts.shift())
+			if sval == nil {
+				continue
+			}
 			if err := set.Insert(sval); err != nil {
 				e.recordStarContainerErr(path, set, nil, sval, v, err)
 			}
 		}
 		return set
 
+	case isDictMapType(goVal.Type()) && (curOpt == "asdict" || !isSetMapType(goVal.Type())):
+		n := goVal.Len()
+		dict := starlark.NewDict(n)
+		for _, k := range sortedMapKeys(goVal) {
+			v := goVal.MapIndex(k)
+			key := e.convertGoKey(fmt.Sprintf("%s[%v]", path, k), k)
+			if key == nil {
+				continue
+			}
+			path := fmt.Sprintf("%s[%s]", path, key.String())
+			sval := e.convertGoValue(path, v, opts.shift())
+			if sval == nil {
+				continue
+			}
+			if err := dict.SetKey(key, sval); err != nil {
+				e.recordStarContainerErr(path, dict, key, sval, v, err)
+			}
+		}
+		return dict
+
 	case isSetMapType(goVal.Type()):
 		n := goVal.Len()
 		set := starlark.NewSet(n)
@@ -290,26 +515,161 @@ func (e *encoder) convertGoValue(path string, goVal reflect.Value, opts tagOpt)
 				continue
 			}
 			path := fmt.Sprintf("%s[%v]", path, k)
-			sval := e.convertGoValue(path, k, opts.shift())
+			sval := e.convertGoKey(path, k)
+			if sval == nil {
+				continue
+			}
 			if err := set.Insert(sval); err != nil {
 				e.recordStarContainerErr(path, set, nil, sval, k, err)
 			}
 		}
 		return set
 
+	case isBigNumType(goVal.Type()):
+		return e.convertBigNum(path, goVal, curOpt)
+
 	case goVal.Kind() == reflect.Struct:
-		n := goVal.NumField()
+		return e.convertStruct(path, goVal, curOpt)
+
+	default:
+		return e.convertUnsupported(path, goVal)
+	}
+}
+
+// converts the Go struct goVal to a starlark Dict, Struct or Module,
+// depending on the opt tag option or the encoder's StructEncoding.
+func (e *encoder) convertStruct(path string, goVal reflect.Value, opt string) starlark.Value {
+	goVal = e.beforeEncode(path, goVal)
+
+	enc := e.structs
+	switch opt {
+	case "asdict":
+		enc = StructAsDict
+	case "asstruct":
+		enc = StructAsStruct
+	case "asmodule":
+		enc = StructAsModule
+	}
+
+	n := goVal.NumField()
+	if enc == StructAsDict {
 		dict := starlark.NewDict(n)
-		e.walkStructEncode(path, goVal, dict)
+		e.encodeStruct(path, goVal, dict)
 		return dict
+	}
+
+	members := make(starlark.StringDict, n)
+	e.encodeStruct(path, goVal, stringDictValue{members})
+	if enc == StructAsModule {
+		return &starlarkstruct.Module{Name: path, Members: members}
+	}
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, members)
+}
+
+// converts the big.Int, big.Float or big.Rat value goVal to a starlark Int or
+// Float, depending on the opt tag option.
+func (e *encoder) convertBigNum(path string, goVal reflect.Value, opt string) starlark.Value {
+	ptr := reflect.New(goVal.Type())
+	ptr.Elem().Set(goVal)
+
+	switch x := ptr.Interface().(type) {
+	case *big.Int:
+		if opt == "asfloat" {
+			f, acc := new(big.Float).SetInt(x).Float64()
+			if acc != big.Exact {
+				e.recordNumberErr(path, starlark.Float(f), goVal, NumCannotExactlyRepresent)
+				return starlark.None
+			}
+			return starlark.Float(f)
+		}
+		return starlark.MakeBigInt(new(big.Int).Set(x))
+
+	case *big.Float:
+		if opt == "asint" {
+			if x.IsInf() || !x.IsInt() {
+				e.recordNumberErr(path, starlark.MakeInt(0), goVal, NumCannotExactlyRepresent)
+				return starlark.None
+			}
+			i, _ := x.Int(nil)
+			return starlark.MakeBigInt(i)
+		}
+		f, acc := x.Float64()
+		if acc != big.Exact {
+			e.recordNumberErr(path, starlark.Float(f), goVal, NumCannotExactlyRepresent)
+			return starlark.None
+		}
+		return starlark.Float(f)
+
+	case *big.Rat:
+		if opt == "asint" {
+			if !x.IsInt() {
+				e.recordNumberErr(path, starlark.MakeInt(0), goVal, NumCannotExactlyRepresent)
+				return starlark.None
+			}
+			return starlark.MakeBigInt(new(big.Int).Set(x.Num()))
+		}
+		f, exact := x.Float64()
+		if !exact {
+			e.recordNumberErr(path, starlark.Float(f), goVal, NumCannotExactlyRepresent)
+			return starlark.None
+		}
+		return starlark.Float(f)
 
 	default:
+		// cannot happen, convertBigNum is only called for big numbers
 		e.recordTypeErr(path, goVal)
-		// return None to avoid issues with invalid starlark values
 		return starlark.None
 	}
 }
 
+// handles the Go value goVal that cannot be converted to a starlark value,
+// according to the UnsupportedPolicy. It returns nil if the value must be
+// skipped.
+func (e *encoder) convertUnsupported(path string, goVal reflect.Value) starlark.Value {
+	switch e.unsupported {
+	case UnsupportedSkip:
+		return nil
+	case UnsupportedStringify:
+		if s, ok := stringify(goVal); ok {
+			return starlark.String(s)
+		}
+	}
+	e.recordTypeErr(path, goVal)
+	// return None to avoid issues with invalid starlark values
+	return starlark.None
+}
+
+// converts the Go map key goKey to a starlark value suitable as a Dict key or
+// a Set element.
+// Arrays and structs are converted to a Tuple of their elements or fields
+// (except for byte arrays, converted to Bytes), other types are converted as
+// any other Go value.
+func (e *encoder) convertGoKey(path string, goKey reflect.Value) starlark.Value {
+	var n int
+	var elem func(int) reflect.Value
+	switch goKey.Kind() {
+	case reflect.Array:
+		if isByteArrayType(goKey.Type()) {
+			// Bytes are hashable
+			return e.convertGoValue(path, goKey, nil)
+		}
+		n, elem = goKey.Len(), goKey.Index
+	case reflect.Struct:
+		n, elem = goKey.NumField(), goKey.Field
+	default:
+		return e.convertGoValue(path, goKey, nil)
+	}
+
+	tupVals := make([]starlark.Value, n)
+	for i := 0; i < n; i++ {
+		if tupVals[i] = e.convertGoKey(path, elem(i)); tupVals[i] == nil {
+			// skip the whole key if any of its parts is skipped
+			return nil
+		}
+	}
+	return starlark.Tuple(tupVals)
+}
+
 func (e *encoder) recordTypeErr(path string, goVal reflect.Value) {
 	err := &TypeError{
 		Op:    OpToStarlark,
@@ -341,6 +701,17 @@ func (e *encoder) recordStarContainerErr(path string, container, key, val starla
 	e.recordErr(err)
 }
 
+func (e *encoder) recordNumberErr(path string, starNum starlark.Value, goVal reflect.Value, reason NumberFailReason) {
+	err := &NumberError{
+		Op:      OpToStarlark,
+		Reason:  reason,
+		Path:    path,
+		StarNum: starNum,
+		GoVal:   goVal,
+	}
+	e.recordErr(err)
+}
+
 func (e *encoder) recordCustomConvErr(path string, goVal reflect.Value, ce error) {
 	err := &CustomConvError{
 		Op:    OpToStarlark,
@@ -367,10 +738,69 @@ func isStructOrPtrType(t reflect.Type) bool {
 	return isStructPtrType(t)
 }
 
+// returns the string representation of v if it implements the error or
+// fmt.Stringer interfaces, either with a value or a pointer receiver.
+func stringify(v reflect.Value) (string, bool) {
+	vals := []reflect.Value{v}
+	if v.CanAddr() {
+		vals = append(vals, v.Addr())
+	}
+	for _, v := range vals {
+		if !v.CanInterface() {
+			continue
+		}
+		switch x := v.Interface().(type) {
+		case error:
+			return x.Error(), true
+		case fmt.Stringer:
+			return x.String(), true
+		}
+	}
+	return "", false
+}
+
+// returns the bytes of v, which must be a []byte or [N]byte value.
+func bytesOf(v reflect.Value) []byte {
+	if v.Kind() == reflect.Slice {
+		return v.Bytes()
+	}
+	b := make([]byte, v.Len())
+	for i := range b {
+		b[i] = byte(v.Index(i).Uint())
+	}
+	return b
+}
+
 func isStructPtrType(t reflect.Type) bool {
 	return t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct
 }
 
+// returns the keys of the map m in a deterministic, sorted order.
+func sortedMapKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return lessMapKey(keys[i], keys[j])
+	})
+	return keys
+}
+
+func lessMapKey(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	default:
+		return fmt.Sprint(a) < fmt.Sprint(b)
+	}
+}
+
 func isTOrPtrTType(t, T reflect.Type) bool {
 	return t == T || (t.Kind() == reflect.Pointer && t.Elem() == T)
 }
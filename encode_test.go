@@ -2,13 +2,18 @@ package starstruct
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"math/big"
+	"net/netip"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	startime "go.starlark.net/lib/time"
 	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
 )
 
 func TestToStarlark(t *testing.T) {
@@ -98,6 +103,29 @@ func TestToStarlark(t *testing.T) {
 			Ss []string `starlark:"sasset,asset,asbytes"`
 		}{Ss: []string{"a", "b"}}, M{}, M{"sasset": set(starlark.Bytes("a"), starlark.Bytes("b"))}, ``},
 
+		{"[N]byte as Bytes", struct{ B [3]byte }{B: [3]byte{'a', 'b', 'c'}}, M{}, M{"B": starlark.Bytes("abc")}, ``},
+		{"*[N]byte as Bytes", struct{ B *[3]byte }{B: &[3]byte{'a', 'b', 'c'}}, M{}, M{"B": starlark.Bytes("abc")}, ``},
+		{"nil *[N]byte", struct{ B *[3]byte }{}, M{}, M{"B": starlark.None}, ``},
+		{"[N]byte as String", struct {
+			B [3]byte `starlark:"b,asstring"`
+		}{B: [3]byte{'a', 'b', 'c'}}, M{}, M{"b": starlark.String("abc")}, ``},
+		{"[N]byte as List", struct {
+			B [2]byte `starlark:"b,aslist"`
+		}{B: [2]byte{1, 2}}, M{}, M{"b": list(starlark.MakeInt(1), starlark.MakeInt(2))}, ``},
+		{"[N]int as List", struct{ Is [2]int }{Is: [2]int{1, 2}}, M{}, M{"Is": list(starlark.MakeInt(1), starlark.MakeInt(2))}, ``},
+		{"[N]float as Tuple", struct {
+			Fs [2]float64 `starlark:"fs,astuple"`
+		}{Fs: [2]float64{1, 2}}, M{}, M{"fs": tup(starlark.Float(1), starlark.Float(2))}, ``},
+		{"[N]string as Set", struct {
+			Ss [1]string `starlark:"ss,asset"`
+		}{Ss: [1]string{"a"}}, M{}, M{"ss": set(starlark.String("a"))}, ``},
+		{"[N][N]int as List of Tuples", struct {
+			M [2][2]int `starlark:"m,aslist,astuple"`
+		}{M: [2][2]int{{1, 2}, {3, 4}}}, M{}, M{"m": list(tup(starlark.MakeInt(1), starlark.MakeInt(2)), tup(starlark.MakeInt(3), starlark.MakeInt(4)))}, ``},
+		{"empty array", struct{ A [0]int }{}, M{}, M{"A": starlark.NewList([]starlark.Value{})}, ``},
+		{"map of byte array keys", struct{ M map[[2]byte]int }{M: map[[2]byte]int{{'a', 'b'}: 1}}, M{}, M{"M": dictKV(starlark.Bytes("ab"), starlark.MakeInt(1))}, ``},
+		{"unsupported array type", struct{ A [1]chan int }{}, M{}, nil, `A[0]: unsupported Go type chan int`},
+
 		{"empty struct", &struct{}{}, M{}, M{}, ``},
 		{"embedded struct no field", &struct{ EmptyStruct }{}, M{}, M{}, ``},
 		{"embedded struct anonymous", &struct{ IntStruct }{IntStruct: IntStruct{I: 1}}, M{}, M{"I": starlark.MakeInt(1)}, ``},
@@ -117,15 +145,43 @@ func TestToStarlark(t *testing.T) {
 		{"map to set", struct{ M map[string]bool }{M: map[string]bool{"x": true}}, M{}, M{"M": set(starlark.String("x"))}, ``},
 		{"map to set with false key", struct{ M map[string]bool }{M: map[string]bool{"x": true, "y": false}}, M{}, M{"M": set(starlark.String("x"))}, ``},
 		{"nil *map", struct{ Mptr *map[string]bool }{}, M{}, M{"Mptr": starlark.None}, ``},
-
-		{"time.Duration encodes as in64", struct{ Ts time.Duration }{Ts: time.Second}, M{}, M{"Ts": starlark.MakeInt(int(time.Second))}, ``},
+		{"map to dict", struct{ M map[string]int }{M: map[string]int{"a": 1}}, M{}, M{"M": dict(M{"a": starlark.MakeInt(1)})}, ``},
+		{"empty map to dict", struct{ M map[string]int }{M: map[string]int{}}, M{}, M{"M": dict(M{})}, ``},
+		{"nil map to dict", struct{ M map[string]int }{}, M{}, M{"M": starlark.None}, ``},
+		{"*map to dict", struct{ M *map[string]string }{M: &map[string]string{"a": "b"}}, M{}, M{"M": dict(M{"a": starlark.String("b")})}, ``},
+		{"map of bool as dict", struct {
+			M map[string]bool `starlark:"m,asdict"`
+		}{M: map[string]bool{"x": false}}, M{}, M{"m": dict(M{"x": starlark.False})}, ``},
+		{"map of struct to dict", struct{ M map[string]IntStruct }{M: map[string]IntStruct{"a": {I: 1}}}, M{}, M{"M": dict(M{"a": dict(M{"I": starlark.MakeInt(1)})})}, ``},
+		{"map of slices as tuples", struct {
+			M map[string][]int `starlark:"m,,astuple"`
+		}{M: map[string][]int{"a": {1}}}, M{}, M{"m": dict(M{"a": tup(starlark.MakeInt(1))})}, ``},
+		{"map of named strings", struct{ M map[myString]myInt }{M: map[myString]myInt{"a": 1}}, M{}, M{"M": dict(M{"a": starlark.MakeInt(1)})}, ``},
+		{"map of int keys", struct{ M map[int]string }{M: map[int]string{-1: "a"}}, M{}, M{"M": dictKV(starlark.MakeInt(-1), starlark.String("a"))}, ``},
+		{"map of uint16 keys", struct{ M map[uint16]bool }{M: map[uint16]bool{80: true}}, M{}, M{"M": set(starlark.MakeInt(80))}, ``},
+		{"map of uint16 keys as dict", struct {
+			M map[uint16]bool `starlark:"m,asdict"`
+		}{M: map[uint16]bool{80: true}}, M{}, M{"m": dictKV(starlark.MakeInt(80), starlark.True)}, ``},
+		{"map of bool keys", struct{ M map[bool]int }{M: map[bool]int{true: 1}}, M{}, M{"M": dictKV(starlark.True, starlark.MakeInt(1))}, ``},
+		{"map of array keys", struct{ M map[[2]string]int }{M: map[[2]string]int{{"a", "b"}: 1}}, M{}, M{"M": dictKV(tup(starlark.String("a"), starlark.String("b")), starlark.MakeInt(1))}, ``},
+		{"map of struct keys", struct{ M map[KeyRoute]string }{M: map[KeyRoute]string{{80, "tcp"}: "a"}}, M{}, M{"M": dictKV(tup(starlark.MakeInt(80), starlark.String("tcp")), starlark.String("a"))}, ``},
+		{"map of nested keys", struct{ M map[KeyNested]*int }{M: map[KeyNested]*int{{Ints: [2]int{1, 2}}: nil}}, M{}, M{"M": dictKV(tup(tup(starlark.MakeInt(1), starlark.MakeInt(2)), starlark.False), starlark.None)}, ``},
+		{"map of struct keys invalid value", struct{ M map[KeyRoute]chan int }{M: map[KeyRoute]chan int{{80, "tcp"}: nil}}, M{}, nil, `M[(80, "tcp")]: unsupported Go type chan int`},
+		{"map of unsupported key type", struct{ M map[*int]int }{M: map[*int]int{nil: 1}}, M{}, nil, `M: unsupported Go type map[*int]int`},
+		{"map of unexported struct key", struct{ M map[KeyUnexp]int }{M: map[KeyUnexp]int{{}: 1}}, M{}, nil, `M: unsupported Go type map[starstruct.KeyUnexp]int`},
+		{"unsupported map value type", struct{ M map[string]chan int }{M: map[string]chan int{"a": make(chan int)}}, M{}, nil, `M["a"]: unsupported Go type chan int`},
+		{"map of slices as sets", struct {
+			M map[string][]int `starlark:"m,,asset"`
+		}{M: map[string][]int{"a": {1}}}, M{}, M{"m": dict(M{"a": set(starlark.MakeInt(1))})}, ``},
+
+		{"time.Duration encodes as lib/time Duration", struct{ Ts time.Duration }{Ts: time.Second}, M{}, M{"Ts": startime.Duration(time.Second)}, ``},
 		{"chan unsupported", struct{ Ch chan int }{Ch: make(chan int)}, M{}, nil, `Ch: unsupported Go type chan int`},
 		{"chan unsupported ignored", struct {
 			Ch chan int `starlark:"-"`
 		}{Ch: make(chan int)}, M{}, M{}, ``},
-		{"slice of funcs as tuples as sets", struct {
-			Fs [][]func() `starlark:"fs,astuple,asset"`
-		}{Fs: [][]func(){{func() {}}}}, M{}, nil, `Fs[0][0]: unsupported Go type func()`},
+		{"slice of chans as tuples as sets", struct {
+			Chs [][]chan int `starlark:"chs,astuple,asset"`
+		}{Chs: [][]chan int{{make(chan int)}}}, M{}, nil, `Chs[0][0]: unsupported Go type chan int`},
 		{"slice of strings as tuple as set", struct {
 			Ss [][]string `starlark:"ss,astuple,asset"`
 		}{Ss: [][]string{{"a"}}}, M{}, M{"ss": tup(set(starlark.String("a")))}, ``},
@@ -136,11 +192,16 @@ func TestToStarlark(t *testing.T) {
 			Strct []struct{} `starlark:"strct,asset"`
 		}{Strct: []struct{}{{}}}, M{}, nil, `Strct[0]: failed to insert Starlark dict into set: unhashable type: dict`},
 		{"invalid map key type for set", struct {
-			M map[struct{}]bool
-		}{M: map[struct{}]bool{{}: true}}, M{}, nil, `M[{}]: failed to insert Starlark dict into set: unhashable type: dict`},
-		{"unsupported map key type", struct {
+			M map[*KeyRoute]bool
+		}{M: map[*KeyRoute]bool{{80, "tcp"}: true}}, M{}, nil, `M[&{80 tcp}]: failed to insert Starlark dict into set: unhashable type: dict`},
+		{"map of struct keys to set", struct{ M map[KeyRoute]bool }{M: map[KeyRoute]bool{{80, "tcp"}: true}}, M{}, M{"M": set(tup(starlark.MakeInt(80), starlark.String("tcp")))}, ``},
+		{"map of array keys to set", struct{ M map[[2]string]bool }{M: map[[2]string]bool{{"a", "b"}: true}}, M{}, M{"M": set(tup(starlark.String("a"), starlark.String("b")))}, ``},
+		{"nil interface map key type", struct {
 			M map[io.Reader]bool
-		}{M: map[io.Reader]bool{io.Reader(nil): true}}, M{}, nil, `M[<nil>]: unsupported Go type io.Reader`},
+		}{M: map[io.Reader]bool{io.Reader(nil): true}}, M{}, M{"M": set(starlark.None)}, ``},
+		{"unsupported interface map key type", struct {
+			M map[any]bool
+		}{M: map[any]bool{[1]chan int{}: true}}, M{}, nil, `M[[<nil>]][0]: unsupported Go type chan int`},
 		{"unsupported slice type", struct {
 			Sl []chan int
 		}{Sl: []chan int{make(chan int)}}, M{}, nil, `Sl[0]: unsupported Go type chan int`},
@@ -164,6 +225,22 @@ func TestToStarlark(t *testing.T) {
 		{"**starlark.Value", struct{ V **starlark.Value }{V: star2ptr}, M{}, nil, `V: unsupported Go type **starlark.Value`},
 		{"wrapped starlark value", struct{ V dummyValue }{V: dummyValue{Value: starlark.MakeInt(1)}}, M{}, nil, `V.Value: unsupported embedded Go type starlark.Value`},
 
+		{"nil any", struct{ A any }{}, M{}, M{"A": starlark.None}, ""},
+		{"nil *any", struct{ A *any }{}, M{}, M{"A": starlark.None}, ""},
+		{"int any", struct{ A any }{A: 1}, M{}, M{"A": starlark.MakeInt(1)}, ""},
+		{"*any", struct{ A *any }{A: anyptr("a")}, M{}, M{"A": starlark.String("a")}, ""},
+		{"nil pointer any", struct{ A any }{A: (*int)(nil)}, M{}, M{"A": starlark.None}, ""},
+		{"pointer any", struct{ A any }{A: iptr(2)}, M{}, M{"A": starlark.MakeInt(2)}, ""},
+		{"struct any", struct{ A any }{A: IntStruct{I: 3}}, M{}, M{"A": dict(M{"I": starlark.MakeInt(3)})}, ""},
+		{"slice any with opts", struct {
+			A any `starlark:"a,astuple"`
+		}{A: []any{"a", 1}}, M{}, M{"a": tup(starlark.String("a"), starlark.MakeInt(1))}, ""},
+		{"map of any", struct{ A map[string]any }{A: map[string]any{"x": true}}, M{}, M{"A": dict(M{"x": starlark.True})}, ""},
+		{"nil stringer interface", struct{ S fmt.Stringer }{}, M{}, M{"S": starlark.None}, ""},
+		{"nil chan stringer interface", struct{ S fmt.Stringer }{S: myStringer(nil)}, M{}, nil, `S: unsupported Go type starstruct.myStringer`},
+		{"starlark value in any", struct{ A any }{A: list(starlark.True)}, M{}, M{"A": list(starlark.True)}, ""},
+		{"unsupported any", struct{ A any }{A: make(chan int)}, M{}, nil, `A: unsupported Go type chan int`},
+
 		{"myBool", struct{ B myBool }{B: true}, M{}, M{"B": starlark.Bool(true)}, ""},
 		{"*myBool", struct{ B *myBool }{B: myTruePtr}, M{}, M{"B": starlark.Bool(true)}, ""},
 		{"myString", struct{ S myString }{S: "abc"}, M{}, M{"S": starlark.String("abc")}, ""},
@@ -206,7 +283,7 @@ func TestToStarlark_InvalidInput(t *testing.T) {
 func TestToStarlark_MaxToErrors(t *testing.T) {
 	type S struct {
 		I  int
-		F  func()
+		C  complex128
 		B  **bool
 		Ch chan byte
 	}
@@ -215,7 +292,7 @@ func TestToStarlark_MaxToErrors(t *testing.T) {
 	t.Run("too many", func(t *testing.T) {
 		err := ToStarlark(S{
 			I:  1,
-			F:  func() {},
+			C:  1i,
 			B:  &b,
 			Ch: make(chan byte),
 		}, nil, MaxToErrors(2))
@@ -226,7 +303,7 @@ func TestToStarlark_MaxToErrors(t *testing.T) {
 
 		var te *TypeError
 		require.ErrorAs(t, errs[0], &te)
-		require.Contains(t, errs[0].Error(), `F: unsupported Go type func()`)
+		require.Contains(t, errs[0].Error(), `C: unsupported Go type complex128`)
 		require.ErrorAs(t, errs[1], &te)
 		require.Contains(t, errs[1].Error(), `B: unsupported Go type **bool`)
 		require.ErrorAs(t, errs[1], &te)
@@ -236,7 +313,7 @@ func TestToStarlark_MaxToErrors(t *testing.T) {
 	t.Run("exactly", func(t *testing.T) {
 		err := ToStarlark(S{
 			I:  1,
-			F:  func() {},
+			C:  1i,
 			B:  &b,
 			Ch: make(chan byte),
 		}, nil, MaxToErrors(3))
@@ -247,7 +324,7 @@ func TestToStarlark_MaxToErrors(t *testing.T) {
 
 		var te *TypeError
 		require.ErrorAs(t, errs[0], &te)
-		require.Contains(t, errs[0].Error(), `F: unsupported Go type func()`)
+		require.Contains(t, errs[0].Error(), `C: unsupported Go type complex128`)
 		require.ErrorAs(t, errs[1], &te)
 		require.Contains(t, errs[1].Error(), `B: unsupported Go type **bool`)
 		require.ErrorAs(t, errs[1], &te)
@@ -255,6 +332,355 @@ func TestToStarlark_MaxToErrors(t *testing.T) {
 	})
 }
 
+func TestToStarlark_OnUnsupported(t *testing.T) {
+	type S struct {
+		I   int
+		Ch  chan int
+		Err any
+		Str fmt.Stringer
+		Ptr *myPtrStringer
+		Fs  []any
+		M   map[string]any
+	}
+	v := S{
+		I:   1,
+		Ch:  make(chan int),
+		Err: myError(0),
+		Str: make(myStringer),
+		Ptr: new(myPtrStringer),
+		Fs:  []any{1, 1i, 2},
+		M:   map[string]any{"a": make(chan int), "b": true},
+	}
+
+	t.Run("error", func(t *testing.T) {
+		err := ToStarlark(v, nil, OnUnsupported(UnsupportedError))
+		require.Error(t, err)
+		errs := err.(interface{ Unwrap() []error }).Unwrap()
+		require.Len(t, errs, 6)
+		require.Contains(t, errs[0].Error(), `Ch: unsupported Go type chan int`)
+		require.Contains(t, errs[1].Error(), `Err: unsupported Go type starstruct.myError`)
+		require.Contains(t, errs[2].Error(), `Str: unsupported Go type starstruct.myStringer`)
+		require.Contains(t, errs[3].Error(), `Ptr: unsupported Go type starstruct.myPtrStringer`)
+		require.Contains(t, errs[4].Error(), `Fs[1]: unsupported Go type complex128`)
+		require.Contains(t, errs[5].Error(), `M["a"]: unsupported Go type chan int`)
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		m := M{}
+		err := ToStarlark(v, m, OnUnsupported(UnsupportedSkip))
+		require.NoError(t, err)
+		require.Equal(t, M{
+			"I":  starlark.MakeInt(1),
+			"Fs": list(starlark.MakeInt(1), starlark.MakeInt(2)),
+			"M":  dict(M{"b": starlark.True}),
+		}, m)
+	})
+
+	t.Run("stringify", func(t *testing.T) {
+		m := M{}
+		err := ToStarlark(v, m, OnUnsupported(UnsupportedStringify))
+		require.Error(t, err)
+		errs := err.(interface{ Unwrap() []error }).Unwrap()
+		require.Len(t, errs, 3)
+		require.Contains(t, errs[0].Error(), `Ch: unsupported Go type chan int`)
+		require.Contains(t, errs[1].Error(), `Fs[1]: unsupported Go type complex128`)
+		require.Contains(t, errs[2].Error(), `M["a"]: unsupported Go type chan int`)
+
+		delete(m, "M")
+		require.Equal(t, M{
+			"I":   starlark.MakeInt(1),
+			"Ch":  starlark.None,
+			"Err": starlark.String("my error"),
+			"Str": starlark.String("my stringer"),
+			"Ptr": starlark.String("my ptr stringer"),
+			"Fs":  list(starlark.MakeInt(1), starlark.None, starlark.MakeInt(2)),
+		}, m)
+	})
+}
+
+func TestToStarlark_BigNum(t *testing.T) {
+	third := big.NewRat(1, 3)
+	half := big.NewRat(1, 2)
+	bigF := new(big.Float).SetInt(tooBig)
+	precF := new(big.Float).SetPrec(100).Quo(big.NewFloat(1), big.NewFloat(3))
+
+	cases := []struct {
+		name string
+		vals any
+		want starlark.Value
+		err  string
+	}{
+		{"big.Int", struct{ V big.Int }{V: *big.NewInt(-3)}, starlark.MakeInt(-3), ``},
+		{"*big.Int", struct{ V *big.Int }{V: tooBig}, starlark.MakeBigInt(tooBig), ``},
+		{"nil *big.Int", struct{ V *big.Int }{}, starlark.None, ``},
+		{"*big.Int as float", struct {
+			V *big.Int `starlark:"V,asfloat"`
+		}{V: tooBig}, starlark.Float(1 << 64), ``},
+		{"*big.Int as float inexact", struct {
+			V *big.Int `starlark:"V,asfloat"`
+		}{V: new(big.Int).Add(tooBig, big.NewInt(1))}, nil, `V: cannot convert Go type big.Int to Starlark float: value cannot be exactly represented`},
+		{"*big.Float", struct{ V *big.Float }{V: big.NewFloat(1.5)}, starlark.Float(1.5), ``},
+		{"*big.Float inexact", struct{ V *big.Float }{V: precF}, nil, `V: cannot convert Go type big.Float to Starlark float: value cannot be exactly represented`},
+		{"*big.Float as int", struct {
+			V *big.Float `starlark:"V,asint"`
+		}{V: bigF}, starlark.MakeBigInt(tooBig), ``},
+		{"*big.Float as int fractional", struct {
+			V *big.Float `starlark:"V,asint"`
+		}{V: big.NewFloat(1.5)}, nil, `V: cannot convert Go type big.Float to Starlark int: value cannot be exactly represented`},
+		{"big.Rat", struct{ V big.Rat }{V: *half}, starlark.Float(0.5), ``},
+		{"*big.Rat inexact", struct{ V *big.Rat }{V: third}, nil, `V: cannot convert Go type big.Rat to Starlark float: value cannot be exactly represented`},
+		{"*big.Rat as int", struct {
+			V *big.Rat `starlark:"V,asint"`
+		}{V: big.NewRat(6, 3)}, starlark.MakeInt(2), ``},
+		{"*big.Rat as int fractional", struct {
+			V *big.Rat `starlark:"V,asint"`
+		}{V: half}, nil, `V: cannot convert Go type big.Rat to Starlark int: value cannot be exactly represented`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := M{}
+			err := ToStarlark(c.vals, m)
+			if c.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.err)
+				return
+			}
+			require.NoError(t, err)
+			eq, err := starlark.Equal(c.want, m["V"])
+			require.NoError(t, err)
+			require.True(t, eq, "want %s, got %s", c.want, m["V"])
+		})
+	}
+}
+
+func TestToStarlark_Time(t *testing.T) {
+	ts := time.Date(2023, 2, 5, 16, 5, 16, 500_000_000, time.UTC)
+	before := time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC)
+
+	cases := []struct {
+		name string
+		vals any
+		want starlark.Value
+		err  string
+	}{
+		{"time.Time", struct{ V time.Time }{V: ts}, startime.Time(ts), ``},
+		{"*time.Time", struct{ V *time.Time }{V: tptr(ts)}, startime.Time(ts), ``},
+		{"nil *time.Time", struct{ V *time.Time }{}, starlark.None, ``},
+		{"time.Time as string", struct {
+			V time.Time `starlark:"V,asstring"`
+		}{V: ts}, starlark.String("2023-02-05T16:05:16.5Z"), ``},
+		{"time.Time as int", struct {
+			V time.Time `starlark:"V,asint"`
+		}{V: ts.Truncate(time.Second)}, starlark.MakeInt(1675613116), ``},
+		{"time.Time as int ms", struct {
+			V time.Time `starlark:"V,asint=ms"`
+		}{V: ts}, starlark.MakeInt(1675613116500), ``},
+		{"time.Time as int before epoch", struct {
+			V time.Time `starlark:"V,asint"`
+		}{V: before}, starlark.MakeInt(-1), ``},
+		{"time.Time as int inexact", struct {
+			V time.Time `starlark:"V,asint=s"`
+		}{V: ts}, nil, `V: cannot convert Go type time.Time to Starlark int: value cannot be exactly represented`},
+		{"time.Duration", struct{ V time.Duration }{V: 5 * time.Minute}, startime.Duration(5 * time.Minute), ``},
+		{"*time.Duration", struct{ V *time.Duration }{V: durptr(time.Hour)}, startime.Duration(time.Hour), ``},
+		{"time.Duration as string", struct {
+			V time.Duration `starlark:"V,asstring"`
+		}{V: 5 * time.Minute}, starlark.String("5m0s"), ``},
+		{"time.Duration as int", struct {
+			V time.Duration `starlark:"V,asint"`
+		}{V: time.Second}, starlark.MakeInt(int(time.Second)), ``},
+		{"time.Duration as int m", struct {
+			V time.Duration `starlark:"V,asint=m"`
+		}{V: 2 * time.Hour}, starlark.MakeInt(120), ``},
+		{"time.Duration as int inexact", struct {
+			V time.Duration `starlark:"V,asint=h"`
+		}{V: 90 * time.Minute}, nil, `V: cannot convert Go type time.Duration to Starlark int: value cannot be exactly represented`},
+		{"slice of time.Duration as strings", struct {
+			V []time.Duration `starlark:"V,aslist,asstring"`
+		}{V: []time.Duration{time.Second, time.Minute}}, list(starlark.String("1s"), starlark.String("1m0s")), ``},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := M{}
+			err := ToStarlark(c.vals, m)
+			if c.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.err)
+				return
+			}
+			require.NoError(t, err)
+			eq, err := starlark.Equal(c.want, m["V"])
+			require.NoError(t, err)
+			require.True(t, eq, "want %s, got %s", c.want, m["V"])
+		})
+	}
+}
+
+func TestToStarlark_TimeInvalidUnit(t *testing.T) {
+	err := ToStarlark(struct {
+		V time.Time     `starlark:"V,asint=days"`
+		W time.Duration `starlark:"W,asint=sec"`
+	}{}, make(starlark.StringDict))
+	require.EqualError(t, err, "V: invalid tag option asint=days: invalid time unit \"days\"\nW: invalid tag option asint=sec: invalid time unit \"sec\"")
+	var te *TagError
+	require.ErrorAs(t, err, &te)
+}
+
+func TestToStarlark_Marshalers(t *testing.T) {
+	type S struct {
+		Level  myLevel
+		LevelP *myLevel
+		Levels []myLevel `starlark:"Levels,astuple"`
+		ID     myID
+		Prefix netip.Prefix
+	}
+
+	lvl := myLevel(2)
+	m := M{}
+	err := ToStarlark(S{
+		Level:  1,
+		LevelP: &lvl,
+		Levels: []myLevel{0, 1},
+		ID:     myID{hi: 1, lo: 2},
+		Prefix: netip.MustParsePrefix("10.0.0.0/8"),
+	}, m)
+	require.NoError(t, err)
+	require.Equal(t, M{
+		"Level":  starlark.String("info"),
+		"LevelP": starlark.String("warn"),
+		"Levels": tup(starlark.String("debug"), starlark.String("info")),
+		"ID":     starlark.Bytes("\x01\x02"),
+		"Prefix": starlark.String("10.0.0.0/8"),
+	}, m)
+
+	t.Run("error", func(t *testing.T) {
+		m := M{}
+		err := ToStarlark(S{Levels: []myLevel{0, 5}}, m)
+		require.Error(t, err)
+
+		var merr *MarshalerError
+		require.ErrorAs(t, err, &merr)
+		require.Equal(t, OpToStarlark, merr.Op)
+		require.Equal(t, "Levels[1]", merr.Path)
+		require.EqualError(t, merr, "Levels[1]: cannot convert Go type starstruct.myLevel to Starlark: invalid level: 5")
+	})
+
+	t.Run("ignored", func(t *testing.T) {
+		m := M{}
+		err := ToStarlark(S{Level: 1, ID: myID{hi: 1, lo: 2}}, m, IgnoreTextMarshalers())
+		require.NoError(t, err)
+		require.Equal(t, starlark.MakeInt(1), m["Level"])
+		require.Equal(t, starlark.None, m["LevelP"])
+		require.Equal(t, 0, m["ID"].(*starlark.Dict).Len())
+	})
+}
+
+func TestToStarlark_StarlarkMarshaler(t *testing.T) {
+	type S struct {
+		P      myPoint
+		Pptr   *myPoint
+		Pnil   *myPoint
+		Points map[string]myPoint
+		C      myCounter
+		Cs     []myCounter
+	}
+
+	m := M{}
+	err := ToStarlark(S{
+		P:      myPoint{1, 2},
+		Pptr:   &myPoint{3, 4},
+		Points: map[string]myPoint{"a": {5, 6}, "b": {-1, 0}},
+		C:      7,
+		Cs:     []myCounter{8},
+	}, m)
+	require.Error(t, err)
+
+	var ce *CustomConvError
+	require.ErrorAs(t, err, &ce)
+	require.Equal(t, OpToStarlark, ce.Op)
+	require.Equal(t, `Points["b"]`, ce.Path)
+	require.EqualError(t, ce, `Points["b"]: custom converter error: negative point: (-1, 0)`)
+
+	pts := m["Points"].(*starlark.Dict)
+	delete(m, "Points")
+	require.Equal(t, M{
+		"P":    tup(starlark.MakeInt(1), starlark.MakeInt(2)),
+		"Pptr": tup(starlark.MakeInt(3), starlark.MakeInt(4)),
+		"Pnil": starlark.None,
+		"C":    starlark.String("count=7"),
+		"Cs":   list(starlark.String("count=8")),
+	}, m)
+	require.Equal(t, starlark.StringDict{
+		"a": tup(starlark.MakeInt(5), starlark.MakeInt(6)),
+		"b": starlark.None,
+	}, toStrDict(pts))
+}
+
+func TestToStarlark_StructEncoding(t *testing.T) {
+	type Srv struct {
+		Addr string `starlark:"addr"`
+		Port int    `starlark:"port"`
+	}
+	type S struct {
+		Server  Srv   `starlark:"server"`
+		Dict    Srv   `starlark:"dict,asdict"`
+		Struct  *Srv  `starlark:"struct,asstruct"`
+		Module  Srv   `starlark:"module,asmodule"`
+		Servers []Srv `starlark:"servers,aslist,asstruct"`
+		Others  []Srv `starlark:"others"`
+	}
+	in := S{
+		Server:  Srv{Addr: "a", Port: 1},
+		Dict:    Srv{Addr: "b", Port: 2},
+		Struct:  &Srv{Addr: "c", Port: 3},
+		Module:  Srv{Addr: "d", Port: 4},
+		Servers: []Srv{{Addr: "e", Port: 5}},
+		Others:  []Srv{{Addr: "f", Port: 6}},
+	}
+	srvMembers := func(addr string, port int) starlark.StringDict {
+		return starlark.StringDict{"addr": starlark.String(addr), "port": starlark.MakeInt(port)}
+	}
+	structMembers := func(v starlark.Value) starlark.StringDict {
+		require.IsType(t, (*starlarkstruct.Struct)(nil), v)
+		sd := make(starlark.StringDict)
+		v.(*starlarkstruct.Struct).ToStringDict(sd)
+		return sd
+	}
+
+	t.Run("default", func(t *testing.T) {
+		m := M{}
+		require.NoError(t, ToStarlark(in, m))
+
+		require.Equal(t, srvMembers("a", 1), toStrDict(m["server"].(*starlark.Dict)))
+		require.Equal(t, srvMembers("b", 2), toStrDict(m["dict"].(*starlark.Dict)))
+		require.Equal(t, srvMembers("c", 3), structMembers(m["struct"]))
+		require.Equal(t, &starlarkstruct.Module{Name: "Module", Members: srvMembers("d", 4)}, m["module"])
+		require.Equal(t, srvMembers("e", 5), structMembers(m["servers"].(*starlark.List).Index(0)))
+	})
+
+	t.Run("as struct", func(t *testing.T) {
+		m := M{}
+		require.NoError(t, ToStarlark(in, m, EncodeStructsAs(StructAsStruct)))
+
+		require.Equal(t, srvMembers("a", 1), structMembers(m["server"]))
+		require.Equal(t, srvMembers("b", 2), toStrDict(m["dict"].(*starlark.Dict)))
+		require.Equal(t, srvMembers("c", 3), structMembers(m["struct"]))
+		require.Equal(t, &starlarkstruct.Module{Name: "Module", Members: srvMembers("d", 4)}, m["module"])
+	})
+
+	t.Run("as module", func(t *testing.T) {
+		m := M{}
+		require.NoError(t, ToStarlark(in, m, EncodeStructsAs(StructAsModule)))
+
+		require.Equal(t, &starlarkstruct.Module{Name: "Server", Members: srvMembers("a", 1)}, m["server"])
+		require.Equal(t, srvMembers("c", 3), structMembers(m["struct"]))
+		require.Equal(t, srvMembers("e", 5), structMembers(m["servers"].(*starlark.List).Index(0)))
+		require.Equal(t, &starlarkstruct.Module{Name: "Others[0]", Members: srvMembers("f", 6)}, m["others"].(*starlark.List).Index(0))
+	})
+}
+
 func TestToStarlark_DuplicateDest(t *testing.T) {
 	type S struct {
 		I   int  `starlark:"int"`
@@ -266,6 +692,30 @@ func TestToStarlark_DuplicateDest(t *testing.T) {
 	require.Equal(t, M{"int": starlark.MakeInt(456)}, m)
 }
 
+func TestToStarlark_MapOrder(t *testing.T) {
+	type S struct {
+		M map[string]int
+	}
+	m := M{}
+	err := ToStarlark(S{M: map[string]int{"c": 3, "a": 1, "b": 2, "d": 4}}, m)
+	require.NoError(t, err)
+
+	keys := m["M"].(*starlark.Dict).Keys()
+	require.Equal(t, []starlark.Value{starlark.String("a"), starlark.String("b"), starlark.String("c"), starlark.String("d")}, keys)
+}
+
+func TestToStarlark_MapOrderIntKeys(t *testing.T) {
+	type S struct {
+		M map[int]int
+	}
+	m := M{}
+	err := ToStarlark(S{M: map[int]int{10: 1, -2: 2, 3: 3}}, m)
+	require.NoError(t, err)
+
+	keys := m["M"].(*starlark.Dict).Keys()
+	require.Equal(t, []starlark.Value{starlark.MakeInt(-2), starlark.MakeInt(3), starlark.MakeInt(10)}, keys)
+}
+
 func TestToStarlark_CustomConverter(t *testing.T) {
 	timet := reflect.TypeOf(time.Now())
 	durt := reflect.TypeOf(time.Second)
@@ -358,7 +808,7 @@ func TestToStarlark_CustomConverter(t *testing.T) {
 		"d3": starlark.MakeInt(6),
 		"ds": list(starlark.MakeInt(7), starlark.MakeInt(8)),
 		"T1": starlark.String("2022-02-02"),
-		"T2": dict(M{}),
+		"T2": startime.Time(date(2022, 3, 3)),
 		"t3": starlark.MakeInt64(date(2022, 4, 4).Unix()),
 		"ts": tup(starlark.MakeInt64(date(2022, 5, 5).Unix()), starlark.MakeInt64(date(2022, 6, 6).Unix())),
 		"N": dict(M{
@@ -3,6 +3,7 @@ package starstruct
 import (
 	"fmt"
 	"reflect"
+	"strings"
 
 	"go.starlark.net/starlark"
 )
@@ -84,9 +85,10 @@ const (
 )
 
 // NumberError represents a numeric conversion error from a starlark Int or
-// Float to a Go number type. The Reason field indicates why the conversion
-// failed: whether it's because the number could not be exactly represented
-// in the target Go number type, or because it was out of range.
+// Float to a Go number type, or from a Go arbitrary-precision number (such as
+// big.Float) to a starlark Int or Float. The Reason field indicates why the
+// conversion failed: whether it's because the number could not be exactly
+// represented in the target number type, or because it was out of range.
 //
 // The distinction is because the source value may be in the range but
 // unrepresentable, for example the float 1.234 is in the range of values for
@@ -99,18 +101,26 @@ const (
 // float32, starstruct checks if the absolute difference is smaller than
 // epsilon.
 type NumberError struct {
+	// Op indicates if this is in a FromStarlark or ToStarlark call.
+	Op ConvOp
 	// Reason indicates the cause of the number conversion failure.
 	Reason NumberFailReason
 	// Path indicates the Go struct path to the field in error.
 	Path string
-	// StarNum is the Starlark integer or float value associated with the error.
+	// StarNum is the Starlark integer or float value associated with the
+	// error. In a ToStarlark call, it is the (possibly approximated) value that
+	// the Go number would convert to.
 	StarNum starlark.Value
-	// GoVal is the target Go value where the number was attempted to be stored.
+	// GoVal is the target Go value where the number was attempted to be stored
+	// in a FromStarlark call, or the source Go value in a ToStarlark call.
 	GoVal reflect.Value
 }
 
 // Error returns the error message for the number conversion failure.
 func (e *NumberError) Error() string {
+	if e.Op == OpToStarlark {
+		return fmt.Sprintf("%s: cannot convert Go type %s to Starlark %s: value cannot be exactly represented", e.Path, e.GoVal.Type(), e.StarNum.Type())
+	}
 	if e.Reason == NumCannotExactlyRepresent {
 		return fmt.Sprintf("%s: cannot assign Starlark %s to Go type %s: value cannot be exactly represented", e.Path, e.StarNum.Type(), e.GoVal.Type())
 	}
@@ -151,3 +161,270 @@ func (e *StarlarkContainerError) Error() string {
 	}
 	return fmt.Sprintf("%s: failed to insert Starlark %s at key %s into %s: %v", e.Path, e.Value.Type(), e.Key.String(), e.Container.Type(), e.Err)
 }
+
+// LengthError represents a conversion error from a starlark sequence (such as
+// a List, Tuple, Set, String or Bytes) to a Go array, when the length of the
+// sequence does not match the length of the array.
+type LengthError struct {
+	// Path indicates the Go struct path to the field in error.
+	Path string
+	// StarVal is the starlark value that failed to convert.
+	StarVal starlark.Value
+	// GoVal is the target Go array value.
+	GoVal reflect.Value
+	// Expected is the length of the Go array.
+	Expected int
+	// Actual is the length of the starlark value.
+	Actual int
+}
+
+// Error returns the error message for the length mismatch.
+func (e *LengthError) Error() string {
+	return fmt.Sprintf("%s: cannot assign Starlark %s to Go type %s: expected length %d, got %d", e.Path, e.StarVal.Type(), e.GoVal.Type(), e.Expected, e.Actual)
+}
+
+// UnionError represents a conversion error from a starlark Dict to a Go
+// interface registered as a discriminated union (see UnionRegistry), when the
+// discriminator key is missing or its value does not identify a registered
+// kind.
+type UnionError struct {
+	// Path indicates the Go struct path to the field in error.
+	Path string
+	// StarVal is the starlark Dict that failed to convert.
+	StarVal starlark.Value
+	// GoVal is the target Go interface value (or pointer to interface).
+	GoVal reflect.Value
+	// Key is the discriminator key of the union.
+	Key string
+	// Kind is the value of the discriminator key, nil if the key is missing.
+	Kind starlark.Value
+	// Allowed is the sorted list of kinds registered for the union.
+	Allowed []string
+}
+
+// Error returns the error message for the union conversion failure.
+func (e *UnionError) Error() string {
+	allowed := strings.Join(e.Allowed, ", ")
+	if e.Kind == nil {
+		return fmt.Sprintf("%s: cannot convert Starlark %s to Go type %s: missing discriminator key %q (allowed kinds: %s)", e.Path, e.StarVal.Type(), e.GoVal.Type(), e.Key, allowed)
+	}
+	return fmt.Sprintf("%s: cannot convert Starlark %s to Go type %s: invalid kind %s for discriminator key %q (allowed kinds: %s)", e.Path, e.StarVal.Type(), e.GoVal.Type(), e.Kind.String(), e.Key, allowed)
+}
+
+// ParseError represents a conversion error from a starlark String to a Go
+// type that parses its textual representation, such as time.Time or
+// time.Duration, when the string cannot be parsed.
+type ParseError struct {
+	// Path indicates the Go struct path to the field in error.
+	Path string
+	// StarVal is the starlark value that failed to parse.
+	StarVal starlark.Value
+	// GoVal is the target Go value.
+	GoVal reflect.Value
+	// Err is the parsing error.
+	Err error
+}
+
+// Unwrap returns the underlying parsing error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Error returns the error message for the parsing failure.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: cannot convert Starlark %s to Go type %s: %v", e.Path, e.StarVal.Type(), e.GoVal.Type(), e.Err)
+}
+
+// MarshalerError wraps an error returned by a Go type's implementation of
+// the encoding.TextMarshaler, encoding.BinaryMarshaler,
+// encoding.TextUnmarshaler or encoding.BinaryUnmarshaler interfaces.
+type MarshalerError struct {
+	// Op indicates if this is in a FromStarlark or ToStarlark call.
+	Op ConvOp
+	// Path indicates the Go struct path to the field in error.
+	Path string
+	// StarVal is the starlark value in a From conversion, nil otherwise.
+	StarVal starlark.Value
+	// GoVal is the Go value associated with the error.
+	GoVal reflect.Value
+	// Err is the error as returned by the marshaler or unmarshaler.
+	Err error
+}
+
+// Unwrap returns the underlying marshaler or unmarshaler error.
+func (e *MarshalerError) Unwrap() error {
+	return e.Err
+}
+
+// Error returns the error message for the marshaler or unmarshaler failure.
+func (e *MarshalerError) Error() string {
+	if e.Op == OpFromStarlark {
+		return fmt.Sprintf("%s: cannot convert Starlark %s to Go type %s: %v", e.Path, e.StarVal.Type(), e.GoVal.Type(), e.Err)
+	}
+	return fmt.Sprintf("%s: cannot convert Go type %s to Starlark: %v", e.Path, e.GoVal.Type(), e.Err)
+}
+
+// HookError wraps an error returned by a Go struct's implementation of the
+// Validator, AfterDecoder or BeforeEncoder interfaces.
+type HookError struct {
+	// Op indicates if this is in a FromStarlark or ToStarlark call.
+	Op ConvOp
+	// Path indicates the Go struct path to the struct in error, which is empty
+	// for the top-level struct.
+	Path string
+	// Hook is the name of the method that returned the error, e.g. "Validate".
+	Hook string
+	// GoVal is the Go struct associated with the error.
+	GoVal reflect.Value
+	// Err is the error as returned by the hook.
+	Err error
+}
+
+// Unwrap returns the underlying hook error.
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// Error returns the error message for the hook failure.
+func (e *HookError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%s: %v", e.Hook, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Path, e.Hook, e.Err)
+}
+
+// ValidationError represents a Go value that does not satisfy a constraint
+// specified in its struct tag, such as `starlark:"port,min=1"`.
+type ValidationError struct {
+	// Op indicates if this is in a FromStarlark or ToStarlark call.
+	Op ConvOp
+	// Path indicates the Go struct path to the field in error.
+	Path string
+	// Rule is the constraint that is not satisfied, as specified in the struct
+	// tag, e.g. "min=1".
+	Rule string
+	// GoVal is the Go value that does not satisfy the constraint, after
+	// conversion in a From conversion.
+	GoVal reflect.Value
+}
+
+// Error returns the error message for the validation failure.
+func (e *ValidationError) Error() string {
+	v := e.GoVal
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.String {
+		return fmt.Sprintf("%s: value %q does not satisfy %s", e.Path, v.String(), e.Rule)
+	}
+	return fmt.Sprintf("%s: value %v does not satisfy %s", e.Path, v, e.Rule)
+}
+
+// MissingFieldError represents a Go struct field with the required struct tag
+// option that has no matching key in the starlark values in a FromStarlark
+// call.
+type MissingFieldError struct {
+	// Path indicates the Go struct path to the missing field.
+	Path string
+	// Key is the starlark key expected for that field.
+	Key string
+}
+
+// Error returns the error message for the missing field.
+func (e *MissingFieldError) Error() string {
+	return fmt.Sprintf("%s: missing required key %q", e.Path, e.Key)
+}
+
+// UnknownKeyError represents a key of the starlark values that does not match
+// any field of the Go struct it is decoded into, reported when the
+// DisallowUnknownKeys option is set.
+type UnknownKeyError struct {
+	// Path indicates the Go struct path to the struct, which is empty for the
+	// top-level struct.
+	Path string
+	// Key is the unknown key, which may not be a string.
+	Key starlark.Value
+	// Suggestions is the list of the closest keys that match a field, if any.
+	Suggestions []string
+}
+
+// Error returns the error message for the unknown key.
+func (e *UnknownKeyError) Error() string {
+	var b strings.Builder
+	if e.Path != "" {
+		b.WriteString(e.Path)
+		b.WriteString(": ")
+	}
+	fmt.Fprintf(&b, "unknown key %s", e.Key)
+	if n := len(e.Suggestions); n > 0 {
+		quoted := make([]string, n)
+		for i, s := range e.Suggestions {
+			quoted[i] = fmt.Sprintf("%q", s)
+		}
+		b.WriteString(" (did you mean ")
+		if n > 1 {
+			b.WriteString(strings.Join(quoted[:n-1], ", "))
+			b.WriteString(" or ")
+		}
+		b.WriteString(quoted[n-1])
+		b.WriteString("?)")
+	}
+	return b.String()
+}
+
+// KeyConflictError represents an entry of a remain field (a field with the
+// remain struct tag option) with a key that matches another field of the
+// struct, in a ToStarlark call.
+type KeyConflictError struct {
+	// Path indicates the Go struct path to the entry of the remain field.
+	Path string
+	// Key is the key of the entry.
+	Key string
+	// GoVal is the Go value of the entry.
+	GoVal reflect.Value
+}
+
+// Error returns the error message for the key conflict.
+func (e *KeyConflictError) Error() string {
+	return fmt.Sprintf("%s: key %q conflicts with a struct field", e.Path, e.Key)
+}
+
+// AmbiguousKeyError represents a Go struct field that matches more than one
+// key of the starlark values, when the CaseInsensitiveKeys option is set.
+type AmbiguousKeyError struct {
+	// Path indicates the Go struct path to the field in error.
+	Path string
+	// Keys is the list of keys that match the field.
+	Keys []string
+}
+
+// Error returns the error message for the ambiguous keys.
+func (e *AmbiguousKeyError) Error() string {
+	quoted := make([]string, len(e.Keys))
+	for i, k := range e.Keys {
+		quoted[i] = fmt.Sprintf("%q", k)
+	}
+	return fmt.Sprintf("%s: ambiguous keys %s match the field", e.Path, strings.Join(quoted, ", "))
+}
+
+// TagError represents an invalid struct tag option of a Go struct field, such
+// as a constraint that cannot be parsed or that does not apply to the type of
+// the field (e.g. `starlark:"port,min=x"`).
+type TagError struct {
+	// Path indicates the Go struct path to the field in error.
+	Path string
+	// Option is the invalid struct tag option, e.g. "min=x".
+	Option string
+	// Err is the error that makes the option invalid.
+	Err error
+}
+
+// Unwrap returns the underlying error.
+func (e *TagError) Unwrap() error {
+	return e.Err
+}
+
+// Error returns the error message for the invalid struct tag option.
+func (e *TagError) Error() string {
+	return fmt.Sprintf("%s: invalid tag option %s: %v", e.Path, e.Option, e.Err)
+}
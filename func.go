@@ -0,0 +1,236 @@
+package starstruct
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+var (
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	threadType = reflect.TypeOf((*starlark.Thread)(nil))
+)
+
+// Func returns a starlark builtin that calls the Go function fn. The name of
+// the builtin is the name of the Go function (e.g. "Foo" for a function Foo
+// declared in any package).
+//
+// The positional arguments of the call are converted to the parameters of fn
+// following the rules of FromStarlark, in order, and its results are
+// converted following the rules of ToStarlark. If the first parameter of fn
+// is a *starlark.Thread, it receives the thread of the call instead of an
+// argument. Keyword arguments are supported if the last (non-variadic)
+// parameter of fn is a struct or a pointer to a struct: if it is not
+// provided as a positional argument, the keyword arguments are converted to
+// that struct as if they were a Dict, e.g. a func(name string, opts Options)
+// can be called as fn("a", timeout="1s"). A keyword argument that does not
+// match a field of the struct fails the call with an UnknownKeyError, as
+// with the DisallowUnknownKeys option (unless the struct has a remain field).
+//
+// The call returns None if fn has no results (other than an error), the
+// converted result if it has one, and a Tuple of the converted results
+// otherwise. If the last result of fn is an error and it is not nil, the
+// call fails with that error. If an argument or a result cannot be
+// converted, the call fails with the conversion errors, which identify the
+// argument by its index (e.g. args[0]) or, for keyword arguments, by the Go
+// struct path of the field (e.g. kwargs.Timeout), as well as the results by
+// their index (e.g. results[0]).
+//
+// Func panics if fn is not a function or is nil, or if the struct tag options
+// of the fields of its parameter or result types are invalid.
+func Func(fn any) *starlark.Builtin {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		if fn == nil {
+			panic("value is not a function: nil")
+		}
+		panic(fmt.Sprintf("value is not a function: %s", v.Type()))
+	}
+	if v.IsNil() {
+		panic(fmt.Sprintf("value is a nil function: %s", v.Type()))
+	}
+
+	fnTyp := v.Type()
+	for i := 0; i < fnTyp.NumIn(); i++ {
+		mustCheckTags(fnTyp.In(i))
+	}
+	for i := 0; i < fnTyp.NumOut(); i++ {
+		mustCheckTags(fnTyp.Out(i))
+	}
+	return newBuiltin(funcName(v), v, &decoder{}, &encoder{})
+}
+
+// returns the unqualified name of the function fn.
+func funcName(fn reflect.Value) string {
+	nm := runtime.FuncForPC(fn.Pointer()).Name()
+	nm = strings.TrimSuffix(nm, "-fm")
+	if ix := strings.LastIndexByte(nm, '.'); ix >= 0 {
+		nm = nm[ix+1:]
+	}
+	return nm
+}
+
+// goFunc is a Go function called from starlark as a builtin.
+type goFunc struct {
+	name string
+	fn   reflect.Value
+
+	// configuration of the decoder and encoder used to convert the arguments
+	// and results, respectively.
+	dec decoder
+	enc encoder
+}
+
+// returns a starlark builtin named name that calls the Go function fn, as
+// described for Func. The arguments are decoded with the configuration of d
+// and the results are encoded with the configuration of e.
+func newBuiltin(name string, fn reflect.Value, d *decoder, e *encoder) *starlark.Builtin {
+	gf := &goFunc{name: name, fn: fn, dec: d.config(), enc: e.config()}
+	return starlark.NewBuiltin(name, gf.call)
+}
+
+func (f *goFunc) call(th *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	in, err := f.args(th, args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	return f.results(f.fn.Call(in))
+}
+
+// decodes the starlark arguments into the Go values to use as arguments to
+// call f.
+func (f *goFunc) args(th *starlark.Thread, args starlark.Tuple, kwargs []starlark.Tuple) ([]reflect.Value, error) {
+	fnTyp := f.fn.Type()
+	params := make([]reflect.Type, fnTyp.NumIn())
+	for i := range params {
+		params[i] = fnTyp.In(i)
+	}
+
+	in := make([]reflect.Value, 0, len(params))
+	if len(params) > 0 && params[0] == threadType {
+		in = append(in, reflect.ValueOf(th))
+		params = params[1:]
+	}
+
+	var variadic reflect.Type
+	if fnTyp.IsVariadic() {
+		variadic = params[len(params)-1].Elem()
+		params = params[:len(params)-1]
+	}
+
+	minArgs, maxArgs := len(params), len(params)
+	hasKwargs := variadic == nil && len(params) > 0 && isStructOrPtrType(params[len(params)-1])
+	if hasKwargs {
+		minArgs--
+	}
+
+	switch {
+	case len(kwargs) > 0 && (!hasKwargs || len(args) == maxArgs):
+		return nil, fmt.Errorf("%s: unexpected keyword argument %s", f.name, kwargs[0][0])
+	case variadic != nil && len(args) < minArgs:
+		return nil, fmt.Errorf("%s: got %d arguments, want at least %d", f.name, len(args), minArgs)
+	case variadic == nil && (len(args) < minArgs || len(args) > maxArgs):
+		if minArgs != maxArgs {
+			return nil, fmt.Errorf("%s: got %d arguments, want %d or %d", f.name, len(args), minArgs, maxArgs)
+		}
+		return nil, fmt.Errorf("%s: got %d arguments, want %d", f.name, len(args), maxArgs)
+	}
+
+	d := f.dec
+	for i, arg := range args {
+		typ := variadic
+		if i < len(params) {
+			typ = params[i]
+		}
+		v := reflect.New(typ).Elem()
+		d.fromStarlarkValue(fmt.Sprintf("args[%d]", i), arg, v, nil)
+		in = append(in, v)
+	}
+
+	if hasKwargs && len(args) < maxArgs {
+		dict := starlark.NewDict(len(kwargs))
+		for _, kv := range kwargs {
+			if err := dict.SetKey(kv[0], kv[1]); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.name, err)
+			}
+		}
+		typ := params[len(params)-1]
+		v := reflect.New(typ).Elem()
+		if typ.Kind() == reflect.Pointer {
+			// always provide a non-nil struct, even without keyword arguments
+			v.Set(reflect.New(typ.Elem()))
+		}
+		d.fromStarlarkValue("kwargs", dict, v, nil)
+		if !d.strict {
+			// unknown keyword arguments are always reported (the strict decoder
+			// already does), so that a typo fails the call.
+			strct := reflect.Indirect(v)
+			// an invalid remain field is already reported by the decoding
+			_, hasRemain, _ := remainField("kwargs", strct)
+			d.checkUnknownKeys("kwargs", strct, dict, "", hasRemain)
+		}
+		in = append(in, v)
+	}
+
+	if len(d.errs) > 0 {
+		return nil, fmt.Errorf("%s: %w", f.name, errors.Join(d.errs...))
+	}
+	return in, nil
+}
+
+// encodes the Go results of a call to f into the starlark value to return.
+func (f *goFunc) results(out []reflect.Value) (starlark.Value, error) {
+	if n := len(out); n > 0 && f.fn.Type().Out(n-1) == errorType {
+		if err := out[n-1]; !err.IsNil() {
+			return nil, err.Interface().(error)
+		}
+		out = out[:n-1]
+	}
+
+	e := f.enc
+	vals := make(starlark.Tuple, len(out))
+	for i, o := range out {
+		sval := e.convertGoValue(fmt.Sprintf("results[%d]", i), o, nil)
+		if sval == nil {
+			// unsupported value, skipped
+			sval = starlark.None
+		}
+		vals[i] = sval
+	}
+	if len(e.errs) > 0 {
+		return nil, fmt.Errorf("%s: %w", f.name, errors.Join(e.errs...))
+	}
+
+	switch len(vals) {
+	case 0:
+		return starlark.None, nil
+	case 1:
+		return vals[0], nil
+	default:
+		return vals, nil
+	}
+}
+
+// returns a copy of the configuration of the decoder, without the errors
+// and the maximum number of errors, so that it can be used to decode the
+// arguments of independent calls.
+func (d *decoder) config() decoder {
+	c := *d
+	c.errs = nil
+	c.maxErrs = 0
+	return c
+}
+
+// returns a copy of the configuration of the encoder, without the errors
+// and the maximum number of errors, so that it can be used to encode the
+// results of independent calls.
+func (e *encoder) config() encoder {
+	c := *e
+	c.errs = nil
+	c.maxErrs = 0
+	return c
+}
@@ -0,0 +1,170 @@
+package starstruct
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.starlark.net/starlark"
+)
+
+func funcJoin(sep string, vals ...int) string {
+	ss := make([]string, len(vals))
+	for i, v := range vals {
+		ss[i] = starlark.MakeInt(v).String()
+	}
+	return strings.Join(ss, sep)
+}
+
+type funcOpts struct {
+	Upper bool     `starlark:"upper"`
+	Tags  []string `starlark:"tags"`
+}
+
+func TestFunc(t *testing.T) {
+	var called bool
+	cases := []struct {
+		name   string
+		fn     any
+		args   starlark.Tuple
+		kwargs []starlark.Tuple
+		want   starlark.Value
+	}{
+		{"no args no results", func() { called = true }, nil, nil, starlark.None},
+		{"variadic", funcJoin, starlark.Tuple{starlark.String(","), starlark.MakeInt(1), starlark.MakeInt(2)}, nil, starlark.String("1,2")},
+		{"variadic empty", funcJoin, starlark.Tuple{starlark.String(",")}, nil, starlark.String("")},
+		{"nil error", func(b bool) (bool, error) { return !b, nil }, starlark.Tuple{starlark.True}, nil, starlark.False},
+		{"many results", func() (int, []string, error) { return 1, []string{"a"}, nil }, nil, nil,
+			starlark.Tuple{starlark.MakeInt(1), starlark.NewList([]starlark.Value{starlark.String("a")})}},
+		{"nil pointer result", func() *funcOpts { return nil }, nil, nil, starlark.None},
+		{"struct result", func() funcOpts { return funcOpts{Upper: true} }, nil, nil,
+			dictKV(starlark.String("upper"), starlark.True, starlark.String("tags"), starlark.None)},
+		{"kwargs", func(s string, opts funcOpts) string {
+			if opts.Upper {
+				s = strings.ToUpper(s)
+			}
+			return s + strings.Join(opts.Tags, "")
+		}, starlark.Tuple{starlark.String("a")}, []starlark.Tuple{
+			{starlark.String("upper"), starlark.True},
+			{starlark.String("tags"), starlark.NewList([]starlark.Value{starlark.String("b")})},
+		}, starlark.String("Ab")},
+		{"kwargs omitted", func(opts *funcOpts) bool { return opts != nil && !opts.Upper }, nil, nil, starlark.True},
+		{"struct as positional", func(opts *funcOpts) bool { return opts.Upper },
+			starlark.Tuple{dict(M{"upper": starlark.True})}, nil, starlark.True},
+		{"thread", func(th *starlark.Thread, i int) string { return th.Name + starlark.MakeInt(i).String() },
+			starlark.Tuple{starlark.MakeInt(1)}, nil, starlark.String("th1")},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			th := starlark.Thread{Name: "th"}
+			got, err := starlark.Call(&th, Func(c.fn), c.args, c.kwargs)
+			require.NoError(t, err)
+			require.Equal(t, c.want, got)
+		})
+	}
+	require.True(t, called)
+}
+
+func TestFunc_Errors(t *testing.T) {
+	errFail := errors.New("fail")
+	fail := func(i int) error {
+		if i > 0 {
+			return errFail
+		}
+		return nil
+	}
+
+	cases := []struct {
+		name   string
+		fn     any
+		args   starlark.Tuple
+		kwargs []starlark.Tuple
+		err    string
+	}{
+		{"returned error", fail, starlark.Tuple{starlark.MakeInt(1)}, nil, `fail`},
+		{"missing arg", fail, nil, nil, `got 0 arguments, want 1`},
+		{"too many args", fail, starlark.Tuple{starlark.MakeInt(1), starlark.MakeInt(2)}, nil, `got 2 arguments, want 1`},
+		{"missing variadic arg", funcJoin, nil, nil, `funcJoin: got 0 arguments, want at least 1`},
+		{"variadic arg", funcJoin, starlark.Tuple{starlark.String(""), starlark.MakeInt(1), starlark.String("x")}, nil,
+			`funcJoin: args[2]: cannot convert Starlark string to Go type int`},
+		{"many args", func(a, b int) {}, starlark.Tuple{starlark.String("x"), starlark.String("y")}, nil,
+			"args[0]: cannot convert Starlark string to Go type int\nargs[1]: cannot convert Starlark string to Go type int"},
+		{"unexpected kwarg", fail, nil, []starlark.Tuple{{starlark.String("i"), starlark.MakeInt(1)}}, `unexpected keyword argument "i"`},
+		{"kwarg conversion", func(opts funcOpts) {}, nil, []starlark.Tuple{{starlark.String("tags"), starlark.MakeInt(1)}},
+			`kwargs.Tags: cannot convert Starlark int to Go type []string`},
+		{"unknown kwarg", func(s string, opts *funcOpts) {}, starlark.Tuple{starlark.String("a")}, []starlark.Tuple{{starlark.String("uper"), starlark.True}},
+			`kwargs: unknown key "uper" (did you mean "upper"?)`},
+		{"result conversion", func() complex128 { return 1i }, nil, nil, `results[0]: unsupported Go type complex128`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var th starlark.Thread
+			_, err := starlark.Call(&th, Func(c.fn), c.args, c.kwargs)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.err)
+		})
+	}
+
+	t.Run("errors.Is", func(t *testing.T) {
+		var th starlark.Thread
+		_, err := starlark.Call(&th, Func(fail), starlark.Tuple{starlark.MakeInt(1)}, nil)
+		require.ErrorIs(t, err, errFail)
+	})
+}
+
+func TestFunc_Panics(t *testing.T) {
+	require.PanicsWithValue(t, "value is not a function: nil", func() {
+		Func(nil)
+	})
+	require.PanicsWithValue(t, "value is not a function: int", func() {
+		Func(1)
+	})
+	require.PanicsWithValue(t, "value is a nil function: func()", func() {
+		Func((func())(nil))
+	})
+}
+
+func TestToStarlark_Funcs(t *testing.T) {
+	type S struct {
+		OnEvent func(string, int) (bool, error) `starlark:"on_event"`
+		Nil     func()
+		Fns     []func() int `starlark:"fns"`
+	}
+	const script = `
+ok = on_event("a", 1)
+n = fns[0]() + fns[1]()
+if Nil != None:
+	fail("expected None")
+`
+
+	var events []string
+	in := S{
+		OnEvent: func(s string, i int) (bool, error) {
+			events = append(events, s)
+			return i > 0, nil
+		},
+		Fns: []func() int{func() int { return 1 }, func() int { return 2 }},
+	}
+	globals := make(starlark.StringDict)
+	require.NoError(t, ToStarlark(in, globals))
+	require.Equal(t, "OnEvent", globals["on_event"].(*starlark.Builtin).Name())
+	require.Equal(t, "Fns[1]", globals["fns"].(*starlark.List).Index(1).(*starlark.Builtin).Name())
+
+	var th starlark.Thread
+	mod, err := starlark.ExecFile(&th, "test", script, globals)
+	require.NoError(t, err)
+	require.Equal(t, starlark.True, mod["ok"])
+	require.Equal(t, starlark.MakeInt(3), mod["n"])
+	require.Equal(t, []string{"a"}, events)
+
+	_, err = starlark.Call(&th, globals["on_event"], starlark.Tuple{starlark.String("b"), starlark.String("c")}, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "OnEvent: args[1]: cannot convert Starlark string to Go type int")
+	var te *TypeError
+	require.ErrorAs(t, err, &te)
+	require.Equal(t, "args[1]", te.Path)
+	require.Equal(t, []string{"a"}, events)
+}
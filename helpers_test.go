@@ -1,6 +1,7 @@
 package starstruct
 
 import (
+	"fmt"
 	"math"
 	"math/big"
 	"time"
@@ -20,6 +21,17 @@ func dict(m M) *starlark.Dict {
 	return d
 }
 
+// creates a dict from the key-value pairs in kvs, in order.
+func dictKV(kvs ...starlark.Value) *starlark.Dict {
+	d := starlark.NewDict(len(kvs) / 2)
+	for i := 0; i < len(kvs); i += 2 {
+		if err := d.SetKey(kvs[i], kvs[i+1]); err != nil {
+			panic(err)
+		}
+	}
+	return d
+}
+
 func toStrDict(d *starlark.Dict) starlark.StringDict {
 	sd := make(starlark.StringDict, d.Len())
 
@@ -54,6 +66,7 @@ func iptr(i int) *int                          { return &i }
 func uptr(i uint) *uint                        { return &i }
 func fptr(f float64) *float64                  { return &f }
 func starptr(v starlark.Value) *starlark.Value { return &v }
+func anyptr(v any) *any                        { return &v }
 func durptr(d time.Duration) *time.Duration    { return &d }
 func tptr(t time.Time) *time.Time              { return &t }
 
@@ -66,6 +79,119 @@ type dummyValue struct {
 
 func (d dummyValue) Type() string { return "dummy" }
 
+// myIterable is a starlark.Iterable that does not implement Len.
+type myIterable []starlark.Value
+
+func (it myIterable) String() string             { return "my_iterable" }
+func (it myIterable) Type() string               { return "my_iterable" }
+func (it myIterable) Freeze()                    {}
+func (it myIterable) Truth() starlark.Bool       { return len(it) > 0 }
+func (it myIterable) Hash() (uint32, error)      { return 0, fmt.Errorf("unhashable: %s", it.Type()) }
+func (it myIterable) Iterate() starlark.Iterator { return starlark.Tuple(it).Iterate() }
+
+// myMapping is a starlark.IterableMapping that is not a Dict.
+type myMapping struct {
+	*starlark.Dict
+}
+
+func (m myMapping) Type() string { return "my_mapping" }
+
+type KeyRoute struct {
+	Port  int
+	Proto string
+}
+
+type KeyNested struct {
+	Ints [2]int
+	B    bool
+}
+
+type KeyUnexp struct {
+	I int
+	s string
+}
+
+type myStringer chan int
+
+func (myStringer) String() string { return "my stringer" }
+
+type myPtrStringer complex64
+
+func (*myPtrStringer) String() string { return "my ptr stringer" }
+
+type myError complex64
+
+func (myError) Error() string { return "my error" }
+
+// myLevel implements encoding.TextMarshaler and encoding.TextUnmarshaler.
+type myLevel int
+
+var levelNames = []string{"debug", "info", "warn"}
+
+func (l myLevel) MarshalText() ([]byte, error) {
+	if l < 0 || int(l) >= len(levelNames) {
+		return nil, fmt.Errorf("invalid level: %d", int(l))
+	}
+	return []byte(levelNames[l]), nil
+}
+
+func (l *myLevel) UnmarshalText(b []byte) error {
+	for i, nm := range levelNames {
+		if nm == string(b) {
+			*l = myLevel(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown level: %q", b)
+}
+
+// myID implements encoding.BinaryMarshaler and encoding.BinaryUnmarshaler.
+type myID struct {
+	hi, lo byte
+}
+
+func (id myID) MarshalBinary() ([]byte, error) {
+	return []byte{id.hi, id.lo}, nil
+}
+
+func (id *myID) UnmarshalBinary(b []byte) error {
+	if len(b) != 2 {
+		return fmt.Errorf("invalid id length: %d", len(b))
+	}
+	id.hi, id.lo = b[0], b[1]
+	return nil
+}
+
+// myPoint implements Marshaler with a value receiver and Unmarshaler.
+type myPoint struct {
+	X, Y int
+}
+
+func (p myPoint) MarshalStarlark() (starlark.Value, error) {
+	if p.X < 0 || p.Y < 0 {
+		return nil, fmt.Errorf("negative point: (%d, %d)", p.X, p.Y)
+	}
+	return starlark.Tuple{starlark.MakeInt(p.X), starlark.MakeInt(p.Y)}, nil
+}
+
+func (p *myPoint) UnmarshalStarlark(v starlark.Value) error {
+	tup, ok := v.(starlark.Tuple)
+	if !ok || len(tup) != 2 {
+		return fmt.Errorf("want a tuple of 2 ints, got %s", v.Type())
+	}
+	if err := starlark.AsInt(tup[0], &p.X); err != nil {
+		return err
+	}
+	return starlark.AsInt(tup[1], &p.Y)
+}
+
+// myCounter implements Marshaler with a pointer receiver.
+type myCounter int
+
+func (c *myCounter) MarshalStarlark() (starlark.Value, error) {
+	return starlark.String(fmt.Sprintf("count=%d", int(*c))), nil
+}
+
 type myInt int
 type myString string
 type myFloat float64
@@ -0,0 +1,83 @@
+package starstruct
+
+import (
+	"reflect"
+)
+
+// Validator is the interface implemented by Go structs that can validate
+// themselves once decoded by FromStarlark.
+type Validator interface {
+	Validate() error
+}
+
+// AfterDecoder is the interface implemented by Go structs that need to run
+// some logic (e.g. to normalize or complete their fields) once decoded by
+// FromStarlark. AfterDecode is called before Validate if the struct
+// implements both interfaces.
+type AfterDecoder interface {
+	AfterDecode() error
+}
+
+// BeforeEncoder is the interface implemented by Go structs that need to run
+// some logic before being encoded by ToStarlark.
+type BeforeEncoder interface {
+	BeforeEncode() error
+}
+
+var beforeEncoderType = reflect.TypeOf((*BeforeEncoder)(nil)).Elem()
+
+// calls the AfterDecode and Validate hooks of the decoded struct strct, if
+// it implements them (with a value or a pointer receiver). Validate is not
+// called if AfterDecode fails.
+func (d *decoder) afterDecode(path string, strct reflect.Value) {
+	ptr := addrOf(strct).Interface()
+	if h, ok := ptr.(AfterDecoder); ok {
+		if err := h.AfterDecode(); err != nil {
+			d.recordHookErr(path, strct, "AfterDecode", err)
+			return
+		}
+	}
+	if h, ok := ptr.(Validator); ok {
+		if err := h.Validate(); err != nil {
+			d.recordHookErr(path, strct, "Validate", err)
+		}
+	}
+}
+
+// calls the BeforeEncode hook of the struct strct, if it implements it (with
+// a value or a pointer receiver), and returns the struct to encode, which is
+// a copy of strct if it is not addressable, so that the changes made by the
+// hook are encoded.
+func (e *encoder) beforeEncode(path string, strct reflect.Value) reflect.Value {
+	if !reflect.PointerTo(strct.Type()).Implements(beforeEncoderType) {
+		return strct
+	}
+
+	ptr := addrOf(strct)
+	if err := ptr.Interface().(BeforeEncoder).BeforeEncode(); err != nil {
+		e.recordHookErr(path, strct, "BeforeEncode", err)
+	}
+	return ptr.Elem()
+}
+
+func (d *decoder) recordHookErr(path string, goVal reflect.Value, hook string, hookErr error) {
+	err := &HookError{
+		Op:    OpFromStarlark,
+		Path:  path,
+		Hook:  hook,
+		GoVal: goVal,
+		Err:   hookErr,
+	}
+	d.recordErr(err)
+}
+
+func (e *encoder) recordHookErr(path string, goVal reflect.Value, hook string, hookErr error) {
+	err := &HookError{
+		Op:    OpToStarlark,
+		Path:  path,
+		Hook:  hook,
+		GoVal: goVal,
+		Err:   hookErr,
+	}
+	e.recordErr(err)
+}
@@ -0,0 +1,187 @@
+package starstruct
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.starlark.net/starlark"
+)
+
+type hookServer struct {
+	Addr string `starlark:"addr"`
+	Port int    `starlark:"port"`
+}
+
+func (s *hookServer) AfterDecode() error {
+	s.Addr = strings.ToLower(s.Addr)
+	if s.Addr == "fail" {
+		return errors.New("after decode failed")
+	}
+	return nil
+}
+
+func (s hookServer) Validate() error {
+	if s.Port <= 0 {
+		return errors.New("port must be positive")
+	}
+	return nil
+}
+
+func (s *hookServer) BeforeEncode() error {
+	if s.Port == 0 {
+		s.Port = 80
+	}
+	if s.Addr == "fail" {
+		return errors.New("before encode failed")
+	}
+	return nil
+}
+
+type HookBase struct {
+	Name string `starlark:"name"`
+}
+
+func (b HookBase) Validate() error {
+	if b.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+type hookConfig struct {
+	HookBase
+	Server  hookServer             `starlark:"server"`
+	Backup  *hookServer            `starlark:"backup"`
+	Servers map[string]*hookServer `starlark:"servers"`
+	List    []hookServer           `starlark:"list"`
+}
+
+func TestFromStarlark_Hooks(t *testing.T) {
+	srv := func(addr string, port int) *starlark.Dict {
+		return dict(M{"addr": starlark.String(addr), "port": starlark.MakeInt(port)})
+	}
+
+	t.Run("success", func(t *testing.T) {
+		var out hookConfig
+		err := FromStarlark(M{
+			"name":    starlark.String("n"),
+			"server":  srv("A", 1),
+			"backup":  srv("B", 2),
+			"servers": dict(M{"c": srv("C", 3)}),
+			"list":    list(srv("D", 4)),
+		}, &out)
+		require.NoError(t, err)
+		require.Equal(t, hookConfig{
+			HookBase: HookBase{Name: "n"},
+			Server:   hookServer{Addr: "a", Port: 1},
+			Backup:   &hookServer{Addr: "b", Port: 2},
+			Servers:  map[string]*hookServer{"c": {Addr: "c", Port: 3}},
+			List:     []hookServer{{Addr: "d", Port: 4}},
+		}, out)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var out hookConfig
+		err := FromStarlark(M{
+			"server":  srv("fail", 1),
+			"backup":  srv("b", 0),
+			"servers": dict(M{"c": srv("c", 0)}),
+			"list":    list(srv("d", 1), dict(M{"port": starlark.String("x")})),
+		}, &out)
+		require.Error(t, err)
+		errs := err.(interface{ Unwrap() []error }).Unwrap()
+		require.Len(t, errs, 4)
+		require.EqualError(t, errs[0], "Server: AfterDecode: after decode failed")
+		require.EqualError(t, errs[1], "Backup: Validate: port must be positive")
+		require.EqualError(t, errs[2], `Servers["c"]: Validate: port must be positive`)
+		// no hook on a struct that failed to decode, nor on its parent
+		require.Contains(t, errs[3].Error(), "List[1].Port: cannot convert Starlark string to Go type int")
+
+		var he *HookError
+		require.ErrorAs(t, errs[1], &he)
+		require.Equal(t, OpFromStarlark, he.Op)
+		require.Equal(t, "Validate", he.Hook)
+		require.Equal(t, "port must be positive", he.Err.Error())
+	})
+
+	t.Run("top-level", func(t *testing.T) {
+		var out hookConfig
+		err := FromStarlark(M{"server": srv("a", 1)}, &out)
+		require.EqualError(t, err, "Validate: name is required")
+	})
+
+	t.Run("not stored", func(t *testing.T) {
+		// a nil pointer is left nil if nothing is set on its struct, and the hooks
+		// of that struct are not called.
+		var out hookConfig
+		err := FromStarlark(M{"name": starlark.String("n"), "server": srv("a", 1), "backup": dict(M{})}, &out)
+		require.NoError(t, err)
+		require.Nil(t, out.Backup)
+
+		// a non-nil pointer is validated even if nothing is set
+		out.Backup = &hookServer{}
+		err = FromStarlark(M{"name": starlark.String("n"), "server": srv("a", 1), "backup": dict(M{})}, &out)
+		require.EqualError(t, err, "Backup: Validate: port must be positive")
+	})
+
+	t.Run("max errors", func(t *testing.T) {
+		var out hookConfig
+		err := FromStarlark(M{
+			"name":   starlark.String("n"),
+			"server": srv("a", 0),
+			"backup": srv("b", 0),
+		}, &out, MaxFromErrors(1))
+		require.Error(t, err)
+		errs := err.(interface{ Unwrap() []error }).Unwrap()
+		require.Len(t, errs, 2)
+		require.EqualError(t, errs[0], "Server: Validate: port must be positive")
+		require.EqualError(t, errs[1], "maximum number of errors reached")
+	})
+}
+
+func TestToStarlark_Hooks(t *testing.T) {
+	t.Run("modifies values", func(t *testing.T) {
+		in := hookConfig{
+			Server:  hookServer{Addr: "a"},
+			Servers: map[string]*hookServer{"b": {Addr: "b"}},
+		}
+		m := M{}
+		require.NoError(t, ToStarlark(in, m))
+		port, _, _ := m["server"].(*starlark.Dict).Get(starlark.String("port"))
+		require.Equal(t, starlark.MakeInt(80), port)
+		// the value was not addressable, so it was called on a copy
+		require.Equal(t, 0, in.Server.Port)
+		// but pointers are modified
+		require.Equal(t, 80, in.Servers["b"].Port)
+
+		require.NoError(t, ToStarlark(&in, m))
+		require.Equal(t, 80, in.Server.Port)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		in := hookConfig{
+			Server: hookServer{Addr: "fail"},
+			Backup: &hookServer{Addr: "fail"},
+		}
+		m := M{}
+		err := ToStarlark(in, m, MaxToErrors(1))
+		require.Error(t, err)
+		errs := err.(interface{ Unwrap() []error }).Unwrap()
+		require.Len(t, errs, 2)
+		require.EqualError(t, errs[0], "Server: BeforeEncode: before encode failed")
+		require.EqualError(t, errs[1], "maximum number of errors reached")
+
+		var he *HookError
+		require.ErrorAs(t, errs[0], &he)
+		require.Equal(t, OpToStarlark, he.Op)
+	})
+}
+
+func TestUnpackArgs_Hooks(t *testing.T) {
+	var srv hookServer
+	err := UnpackArgs("server", starlark.Tuple{starlark.String("A")}, nil, &srv)
+	require.EqualError(t, err, "server: Validate: port must be positive")
+	require.Equal(t, "a", srv.Addr)
+}
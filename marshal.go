@@ -0,0 +1,242 @@
+package starstruct
+
+import (
+	"encoding"
+	"reflect"
+
+	"go.starlark.net/starlark"
+)
+
+// Marshaler is the interface implemented by Go types that can convert
+// themselves to a starlark value. It takes precedence over the standard
+// conversion rules in ToStarlark, and may be implemented with a value or a
+// pointer receiver.
+type Marshaler interface {
+	MarshalStarlark() (starlark.Value, error)
+}
+
+// Unmarshaler is the interface implemented by Go types that can decode a
+// starlark value into themselves. It takes precedence over the standard
+// conversion rules in FromStarlark, and must be implemented with a pointer
+// receiver to be able to modify the value.
+type Unmarshaler interface {
+	UnmarshalStarlark(starlark.Value) error
+}
+
+// IgnoreTextMarshalers disables the use of the encoding.TextMarshaler and
+// encoding.BinaryMarshaler interfaces when converting Go values to starlark,
+// so that those values are converted following the standard rules for their
+// type.
+func IgnoreTextMarshalers() ToOption {
+	return func(e *encoder) {
+		e.ignoreMarshalers = true
+	}
+}
+
+// IgnoreTextUnmarshalers disables the use of the encoding.TextUnmarshaler and
+// encoding.BinaryUnmarshaler interfaces when converting starlark values to
+// Go, so that those values are converted following the standard rules for
+// their type.
+func IgnoreTextUnmarshalers() FromOption {
+	return func(d *decoder) {
+		d.ignoreUnmarshalers = true
+	}
+}
+
+var (
+	marshalerType         = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType       = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textMarshalerType     = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// returns true if t implements Marshaler, either with a value or a pointer
+// receiver.
+func isStarlarkMarshalerType(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(marshalerType)
+}
+
+// returns true if t or the type pointed to by t implements Unmarshaler with a
+// pointer receiver.
+func isStarlarkUnmarshalerType(t reflect.Type) bool {
+	if t.Kind() != reflect.Pointer {
+		t = reflect.PointerTo(t)
+	}
+	return t.Implements(unmarshalerType)
+}
+
+// returns true if t implements encoding.TextMarshaler or
+// encoding.BinaryMarshaler, either with a value or a pointer receiver.
+func isMarshalerType(t reflect.Type) bool {
+	pt := reflect.PointerTo(t)
+	return pt.Implements(textMarshalerType) || pt.Implements(binaryMarshalerType)
+}
+
+// returns true if t or the type pointed to by t implements
+// encoding.TextUnmarshaler or encoding.BinaryUnmarshaler with a pointer
+// receiver.
+func isUnmarshalerType(t reflect.Type) bool {
+	if t.Kind() != reflect.Pointer {
+		t = reflect.PointerTo(t)
+	}
+	return t.Implements(textUnmarshalerType) || t.Implements(binaryUnmarshalerType)
+}
+
+func isStringOrBytes(v starlark.Value) bool {
+	switch v.(type) {
+	case starlark.String, starlark.Bytes:
+		return true
+	}
+	return false
+}
+
+// returns a pointer to goVal, or to a copy of goVal if it is not
+// addressable, so that methods with both value and pointer receivers can be
+// called.
+func addrOf(goVal reflect.Value) reflect.Value {
+	if goVal.CanAddr() {
+		return goVal.Addr()
+	}
+	ptr := reflect.New(goVal.Type())
+	ptr.Elem().Set(goVal)
+	return ptr
+}
+
+// converts the Go value goVal, which must implement Marshaler, by calling its
+// MarshalStarlark method. A nil starlark value is converted to None.
+func (e *encoder) convertStarlarkMarshaler(path string, goVal reflect.Value) starlark.Value {
+	m := addrOf(goVal).Interface().(Marshaler)
+	sval, err := m.MarshalStarlark()
+	if err != nil {
+		e.recordCustomConvErr(path, goVal, err)
+		return starlark.None
+	}
+	if sval == nil {
+		return starlark.None
+	}
+	return sval
+}
+
+// converts the Go value goVal, which must implement encoding.TextMarshaler or
+// encoding.BinaryMarshaler, to a starlark String or Bytes, respectively. If
+// it implements both, it is converted to a String.
+func (e *encoder) convertMarshaler(path string, goVal reflect.Value) starlark.Value {
+	switch m := addrOf(goVal).Interface().(type) {
+	case encoding.TextMarshaler:
+		b, err := m.MarshalText()
+		if err != nil {
+			e.recordMarshalerErr(path, goVal, err)
+			return starlark.None
+		}
+		return starlark.String(b)
+
+	case encoding.BinaryMarshaler:
+		b, err := m.MarshalBinary()
+		if err != nil {
+			e.recordMarshalerErr(path, goVal, err)
+			return starlark.None
+		}
+		return starlark.Bytes(b)
+
+	default:
+		// cannot happen as convertMarshaler is called only if goVal is a marshaler
+		return e.convertUnsupported(path, goVal)
+	}
+}
+
+func (e *encoder) recordMarshalerErr(path string, goVal reflect.Value, marshalErr error) {
+	err := &MarshalerError{
+		Op:    OpToStarlark,
+		Path:  path,
+		GoVal: goVal,
+		Err:   marshalErr,
+	}
+	e.recordErr(err)
+}
+
+// decodes the starlark value v into fld, which must be a type (or a pointer
+// to a type) that implements Unmarshaler, by calling its UnmarshalStarlark
+// method. Following the behavior of JSON unmarshaling, the method is called
+// on the existing value, allocating it if fld is a nil pointer.
+func (d *decoder) setFieldStarlarkUnmarshaler(path string, fld reflect.Value, v starlark.Value) {
+	var ptr reflect.Value
+	switch {
+	case fld.Kind() != reflect.Pointer:
+		ptr = fld.Addr()
+	case fld.IsNil():
+		ptr = reflect.New(fld.Type().Elem())
+	default:
+		ptr = fld
+	}
+
+	u := ptr.Interface().(Unmarshaler)
+	if err := u.UnmarshalStarlark(v); err != nil {
+		d.recordCustomConvErr(path, v, fld, err)
+		return
+	}
+	if fld.Kind() == reflect.Pointer && fld.IsNil() {
+		fld.Set(ptr)
+	}
+}
+
+// decodes the starlark String or Bytes v into fld, which must be a type (or
+// a pointer to a type) that implements encoding.TextUnmarshaler or
+// encoding.BinaryUnmarshaler. A String is preferably decoded with
+// UnmarshalText and Bytes with UnmarshalBinary. The value is unmarshaled into
+// a new zero value, so that fld is unmodified on failure.
+func (d *decoder) setFieldUnmarshaler(path string, fld reflect.Value, v starlark.Value) {
+	typ := fld.Type()
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	ptr := reflect.New(typ)
+	tu, isText := ptr.Interface().(encoding.TextUnmarshaler)
+	bu, isBinary := ptr.Interface().(encoding.BinaryUnmarshaler)
+
+	var err error
+	switch v := v.(type) {
+	case starlark.String:
+		if isText {
+			err = tu.UnmarshalText([]byte(v))
+		} else {
+			err = bu.UnmarshalBinary([]byte(v))
+		}
+	case starlark.Bytes:
+		if isBinary {
+			err = bu.UnmarshalBinary([]byte(v))
+		} else {
+			err = tu.UnmarshalText([]byte(v))
+		}
+	default:
+		// cannot happen as setFieldUnmarshaler is called only for String or Bytes
+		d.recordTypeErr(path, v, fld)
+		return
+	}
+	if err != nil {
+		d.recordUnmarshalerErr(path, v, fld, err)
+		return
+	}
+
+	// support a single-level of indirection, in case the value may be None
+	if fld.Kind() == reflect.Pointer {
+		if fld.IsNil() {
+			fld.Set(ptr)
+			return
+		}
+		fld = fld.Elem()
+	}
+	fld.Set(ptr.Elem())
+}
+
+func (d *decoder) recordUnmarshalerErr(path string, starVal starlark.Value, goVal reflect.Value, e error) {
+	err := &MarshalerError{
+		Op:      OpFromStarlark,
+		Path:    path,
+		StarVal: starVal,
+		GoVal:   goVal,
+		Err:     e,
+	}
+	d.recordErr(err)
+}
@@ -0,0 +1,116 @@
+package starstruct
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// Methods is a marker type to expose methods of a struct as starlark
+// builtins when it is converted by ToStarlark. It is used as the type of a
+// blank field of the struct, the names of the methods to expose being listed
+// in its starlark struct tag, separated by commas:
+//
+//	type Server struct {
+//		_    starstruct.Methods `starlark:"URL,Ping"`
+//		Host string             `starlark:"host"`
+//	}
+//
+// See ExposeMethods for details on how the methods are exposed. A name that
+// is not an exported method of the struct is recorded as a TagError.
+type Methods struct{}
+
+var methodsType = reflect.TypeOf(Methods{})
+
+// ExposeMethods exposes the methods of the struct type typ (which may be a
+// pointer to a struct) listed in names as starlark builtins when a value of
+// that type is converted by ToStarlark. It can be provided multiple times,
+// and adds to the methods listed in a Methods field of the struct, if any.
+//
+// The builtins are set in the resulting Dict, Struct or Module under the
+// name of the method (or in the destination StringDict for the top-level
+// struct), after the fields of the struct. The methods are bound to the
+// converted Go value, so that methods with a pointer receiver may modify it,
+// unless it is not addressable (e.g. if a struct, instead of a pointer to a
+// struct, is provided to ToStarlark) in which case they are bound to a copy.
+//
+// The arguments and results of the calls are converted as described for
+// Func, but with the options of the call to ToStarlark that exposed the
+// method.
+//
+// It panics if typ is not a struct or a pointer to a struct, or if it has no
+// exported method for one of the names.
+func ExposeMethods(typ reflect.Type, names ...string) ToOption {
+	strctTyp := typ
+	if strctTyp.Kind() == reflect.Pointer {
+		strctTyp = strctTyp.Elem()
+	}
+	if strctTyp.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("type is not a struct or a pointer to a struct: %s", typ))
+	}
+	for _, nm := range names {
+		if _, ok := reflect.PointerTo(strctTyp).MethodByName(nm); !ok {
+			panic(fmt.Sprintf("type %s has no exported method %s", typ, nm))
+		}
+	}
+
+	return func(e *encoder) {
+		if e.methods == nil {
+			e.methods = make(map[reflect.Type][]string)
+		}
+		e.methods[strctTyp] = append(e.methods[strctTyp], names...)
+	}
+}
+
+// returns the names of the methods to expose for the struct type typ, from
+// the ExposeMethods options and its Methods fields.
+func (e *encoder) methodNames(typ reflect.Type) []string {
+	names := e.methods[typ]
+	count := typ.NumField()
+	for i := 0; i < count; i++ {
+		fldTyp := typ.Field(i)
+		if fldTyp.Type != methodsType {
+			continue
+		}
+		for _, nm := range strings.Split(fldTyp.Tag.Get("starlark"), ",") {
+			if nm = strings.TrimSpace(nm); nm != "" {
+				names = append(names, nm)
+			}
+		}
+	}
+	return names
+}
+
+// sets the methods to expose for the struct strct as builtins in dst. A
+// method listed in a Methods field that does not exist is recorded as a
+// TagError.
+func (e *encoder) exposeMethods(path string, strct reflect.Value, dst dictGetSetter) {
+	names := e.methodNames(strct.Type())
+	if len(names) == 0 {
+		return
+	}
+
+	ptr := addrOf(strct)
+	dec := decoder{unions: e.unions}
+	for _, nm := range names {
+		path := path
+		if path != "" {
+			path += "."
+		}
+		path += nm
+
+		m := ptr.MethodByName(nm)
+		if !m.IsValid() {
+			e.recordErr(&TagError{Path: path, Option: nm, Err: fmt.Errorf("type %s has no exported method %s", strct.Type(), nm)})
+			continue
+		}
+
+		key := starlark.String(nm)
+		fn := newBuiltin(nm, m, &dec, e)
+		if err := dst.SetKey(key, fn); err != nil {
+			e.recordStarContainerErr(path, dst, key, fn, m, err)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package starstruct
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	startime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+)
+
+type methNode struct {
+	Name string `starlark:"name"`
+	Port int    `starlark:"port"`
+}
+
+func (n methNode) URL() string {
+	return fmt.Sprintf("http://%s:%d", n.Name, n.Port)
+}
+
+type methCluster struct {
+	_     Methods    `starlark:"NodeByName, Add"`
+	Nodes []methNode `starlark:"nodes"`
+	Calls int        `starlark:"calls"`
+}
+
+func (c *methCluster) NodeByName(name string) (*methNode, error) {
+	c.Calls++
+	for i := range c.Nodes {
+		if c.Nodes[i].Name == name {
+			return &c.Nodes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no node named %q", name)
+}
+
+type methAddOpts struct {
+	Port    int           `starlark:"port"`
+	Timeout time.Duration `starlark:"timeout"`
+}
+
+func (c *methCluster) Add(names ...string) int {
+	for _, nm := range names {
+		c.Nodes = append(c.Nodes, methNode{Name: nm})
+	}
+	return len(c.Nodes)
+}
+
+func (c *methCluster) Configure(th *starlark.Thread, name string, opts methAddOpts) (string, time.Duration) {
+	return fmt.Sprintf("%s:%s:%d", th.Name, name, opts.Port), opts.Timeout
+}
+
+func (c methCluster) Nothing() {}
+
+func TestExposeMethods(t *testing.T) {
+	const script = `
+n = NodeByName("b")
+url = n["URL"]()
+count = Add("c", "d")
+count = Add() + count
+res = Configure("a", port=80, timeout="1s")
+res2 = Configure("b", {"port": 81})
+none = Nothing()
+`
+
+	in := methCluster{Nodes: []methNode{{Name: "a", Port: 1}, {Name: "b", Port: 2}}}
+	globals := make(starlark.StringDict)
+	err := ToStarlark(&in, globals,
+		ExposeMethods(reflect.TypeOf(methNode{}), "URL"),
+		ExposeMethods(reflect.TypeOf(&methCluster{}), "Configure"),
+		ExposeMethods(reflect.TypeOf(methCluster{}), "Nothing"))
+	require.NoError(t, err)
+	require.Contains(t, globals, "nodes")
+	require.Contains(t, globals, "NodeByName")
+	require.NotContains(t, globals, "_")
+
+	th := starlark.Thread{Name: "th"}
+	mod, err := starlark.ExecFile(&th, "test", script, globals)
+	require.NoError(t, err)
+
+	require.Equal(t, starlark.String("http://b:2"), mod["url"])
+	require.Equal(t, starlark.MakeInt(8), mod["count"])
+	require.Equal(t, starlark.Tuple{starlark.String("th:a:80"), startime.Duration(time.Second)}, mod["res"])
+	require.Equal(t, starlark.Tuple{starlark.String("th:b:81"), startime.Duration(0)}, mod["res2"])
+	require.Equal(t, starlark.None, mod["none"])
+
+	// pointer receivers modify the original value
+	require.Equal(t, 1, in.Calls)
+	require.Len(t, in.Nodes, 4)
+}
+
+func TestExposeMethods_StructEncoding(t *testing.T) {
+	type S struct {
+		Node methNode `starlark:"node,asstruct"`
+	}
+	const script = `
+url = node.URL()
+`
+
+	globals := make(starlark.StringDict)
+	err := ToStarlark(S{Node: methNode{Name: "x", Port: 3}}, globals, ExposeMethods(reflect.TypeOf(methNode{}), "URL"))
+	require.NoError(t, err)
+
+	var th starlark.Thread
+	mod, err := starlark.ExecFile(&th, "test", script, globals)
+	require.NoError(t, err)
+	require.Equal(t, starlark.String("http://x:3"), mod["url"])
+
+	// methods are ignored when decoding
+	var out S
+	require.NoError(t, FromStarlark(globals, &out))
+	require.Equal(t, S{Node: methNode{Name: "x", Port: 3}}, out)
+}
+
+func TestExposeMethods_Errors(t *testing.T) {
+	cases := []struct {
+		script string
+		err    string
+	}{
+		{`NodeByName("z")`, `no node named "z"`},
+		{`NodeByName(1)`, `NodeByName: args[0]: cannot convert Starlark int to Go type string`},
+		{`NodeByName()`, `NodeByName: got 0 arguments, want 1`},
+		{`NodeByName("a", "b")`, `NodeByName: got 2 arguments, want 1`},
+		{`NodeByName(name="a")`, `NodeByName: unexpected keyword argument "name"`},
+		{`Add(1)`, `Add: args[0]: cannot convert Starlark int to Go type string`},
+		{`Configure()`, `Configure: got 0 arguments, want 1 or 2`},
+		{`Configure("a", {}, port=1)`, `Configure: unexpected keyword argument "port"`},
+		{`Configure("a", port="x")`, `Configure: kwargs.Port: cannot convert Starlark string to Go type int`},
+		{`Configure("a", timeout=True)`, `Configure: kwargs.Timeout: cannot convert Starlark bool to Go type time.Duration`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.script, func(t *testing.T) {
+			in := methCluster{Nodes: []methNode{{Name: "a"}}}
+			globals := make(starlark.StringDict)
+			require.NoError(t, ToStarlark(&in, globals, ExposeMethods(reflect.TypeOf(in), "Configure")))
+
+			var th starlark.Thread
+			_, err := starlark.ExecFile(&th, "test", c.script, globals)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), c.err)
+			if strings.Contains(c.err, "cannot convert") {
+				var te *TypeError
+				require.ErrorAs(t, err, &te)
+			}
+		})
+	}
+
+	t.Run("result conversion", func(t *testing.T) {
+		globals := make(starlark.StringDict)
+		require.NoError(t, ToStarlark(methFuncResult{}, globals))
+
+		var th starlark.Thread
+		_, err := starlark.Call(&th, globals["Fn"], nil, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "Fn: results[0]: unsupported Go type chan int")
+	})
+
+	t.Run("wrapped error", func(t *testing.T) {
+		globals := make(starlark.StringDict)
+		require.NoError(t, ToStarlark(methFuncResult{}, globals))
+
+		var th starlark.Thread
+		_, err := starlark.Call(&th, globals["Err"], nil, nil)
+		require.ErrorIs(t, err, errMethFunc)
+	})
+}
+
+var errMethFunc = errors.New("fail")
+
+type methFuncResult struct {
+	_ Methods `starlark:"Fn,Err"`
+}
+
+func (methFuncResult) Fn() chan int { return nil }
+func (methFuncResult) Err() error   { return errMethFunc }
+
+func TestExposeMethods_Panics(t *testing.T) {
+	require.PanicsWithValue(t, "type is not a struct or a pointer to a struct: int", func() {
+		ExposeMethods(reflect.TypeOf(1), "X")
+	})
+	require.PanicsWithValue(t, "type starstruct.methNode has no exported method Nope", func() {
+		ExposeMethods(reflect.TypeOf(methNode{}), "Nope")
+	})
+}
+
+func TestExposeMethods_MissingMethod(t *testing.T) {
+	type S struct {
+		_    Methods `starlark:"Nope"`
+		Name string  `starlark:"name"`
+	}
+	m := make(starlark.StringDict)
+	err := ToStarlark(S{Name: "a"}, m)
+	require.EqualError(t, err, "Nope: invalid tag option Nope: type starstruct.S has no exported method Nope")
+	var te *TagError
+	require.ErrorAs(t, err, &te)
+	require.Equal(t, starlark.String("a"), m["name"])
+}
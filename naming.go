@@ -0,0 +1,159 @@
+package starstruct
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+
+	"go.starlark.net/starlark"
+)
+
+// NamingStrategy returns the starlark key of a Go struct field that has no
+// starlark name in its struct tag, given the name of the field. The naming
+// strategy is set for FromStarlark with the FieldNamingFrom option (which
+// also applies to UnpackArgs, Bind and Constructor) and for ToStarlark with
+// the FieldNamingTo option, so that the same strategy can be used in both
+// directions. Any func with that signature can be used as a custom strategy.
+//
+// By default, ToStarlark uses the field name as key, and FromStarlark matches
+// the field name or, if there is no such key, the field name in lowercase.
+// When a naming strategy is set, only the key that it returns is used in both
+// directions.
+type NamingStrategy func(fieldName string) string
+
+// IdentityNaming is the NamingStrategy that uses the field name as-is, e.g.
+// MaxConns.
+func IdentityNaming(fieldName string) string {
+	return fieldName
+}
+
+// LowerNaming is the NamingStrategy that uses the field name in lowercase,
+// e.g. maxconns.
+func LowerNaming(fieldName string) string {
+	return strings.ToLower(fieldName)
+}
+
+// SnakeCaseNaming is the NamingStrategy that uses the field name in
+// snake_case, e.g. max_conns for MaxConns and http_server for HTTPServer.
+func SnakeCaseNaming(fieldName string) string {
+	return splitWords(fieldName, '_')
+}
+
+// KebabCaseNaming is the NamingStrategy that uses the field name in
+// kebab-case, e.g. max-conns for MaxConns and http-server for HTTPServer.
+func KebabCaseNaming(fieldName string) string {
+	return splitWords(fieldName, '-')
+}
+
+// returns the camel-case name in lowercase, with sep between its words. A
+// word starts at an uppercase letter that follows a lowercase letter or a
+// digit, or that is followed by a lowercase letter in a sequence of uppercase
+// letters (e.g. HTTPServer is http and server).
+func splitWords(name string, sep rune) string {
+	rs := []rune(name)
+	var b strings.Builder
+	for i, r := range rs {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := rs[i-1]
+			nextLower := i+1 < len(rs) && unicode.IsLower(rs[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteRune(sep)
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// FieldNamingFrom sets the NamingStrategy used by FromStarlark to match the
+// fields that have no starlark name in their struct tag.
+func FieldNamingFrom(ns NamingStrategy) FromOption {
+	return func(d *decoder) {
+		d.naming = ns
+	}
+}
+
+// FieldNamingTo sets the NamingStrategy used by ToStarlark to name the fields
+// that have no starlark name in their struct tag.
+func FieldNamingTo(ns NamingStrategy) ToOption {
+	return func(e *encoder) {
+		e.naming = ns
+	}
+}
+
+// CaseInsensitiveKeys sets FromStarlark to match the keys of the starlark
+// values with the names of the fields (from their struct tag or the naming
+// strategy) regardless of case, e.g. the key maxConns matches a MaxConns
+// field with the LowerNaming strategy. If more than one key matches a field,
+// an AmbiguousKeyError is recorded and the field is left unmodified.
+func CaseInsensitiveKeys() FromOption {
+	return func(d *decoder) {
+		d.foldCase = true
+	}
+}
+
+// returns the starlark key of the field named fieldName, without a starlark
+// name in its struct tag, according to the naming strategy ns. If ns is nil,
+// the default strategy is used, and tryLower is true to indicate that the
+// lowercase key can also match the field when decoding.
+func fieldKey(ns NamingStrategy, fieldName string) (key string, tryLower bool) {
+	if ns == nil {
+		return fieldName, true
+	}
+	return ns(fieldName), false
+}
+
+// index of the string keys of a dict, by their lowercase value.
+type foldIndex map[string][]starlark.String
+
+func newFoldIndex(dict dictGetSetter) foldIndex {
+	idx := make(foldIndex)
+	for _, k := range dictKeys(dict) {
+		if s, ok := k.(starlark.String); ok {
+			lower := strings.ToLower(string(s))
+			idx[lower] = append(idx[lower], s)
+		}
+	}
+	return idx
+}
+
+// returns the keys that match key regardless of case.
+func (idx foldIndex) lookup(key string) []starlark.String {
+	return idx[strings.ToLower(key)]
+}
+
+// returns the starlark keys that match the fields of the struct type typ,
+// mapped to the key to suggest for that field, as for structKeys. If the
+// CaseInsensitiveKeys option is set, the keys are in lowercase.
+func (d *decoder) fieldKeys(typ reflect.Type) map[string]string {
+	keys := structKeys(typ, d.naming, true, nil)
+	if !d.foldCase {
+		return keys
+	}
+	lower := make(map[string]string, len(keys))
+	for k, v := range keys {
+		lower[strings.ToLower(k)] = v
+	}
+	return lower
+}
+
+// returns true if the key matches a field in keys, as returned by fieldKeys.
+func (d *decoder) isFieldKey(keys map[string]string, key string) bool {
+	if d.foldCase {
+		key = strings.ToLower(key)
+	}
+	_, ok := keys[key]
+	return ok
+}
+
+func (d *decoder) recordAmbiguousKeyErr(path string, keys []starlark.String) {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = string(k)
+	}
+	err := &AmbiguousKeyError{
+		Path: path,
+		Keys: names,
+	}
+	d.recordErr(err)
+}
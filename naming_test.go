@@ -0,0 +1,164 @@
+package starstruct
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.starlark.net/starlark"
+)
+
+func TestNamingStrategies(t *testing.T) {
+	cases := []struct {
+		in, snake, kebab string
+	}{
+		{"MaxConns", "max_conns", "max-conns"},
+		{"HTTPServer", "http_server", "http-server"},
+		{"UserID", "user_id", "user-id"},
+		{"ID", "id", "id"},
+		{"Ipv4Addr", "ipv4_addr", "ipv4-addr"},
+		{"Version2", "version2", "version2"},
+		{"already_snake", "already_snake", "already_snake"},
+		{"X", "x", "x"},
+		{"", "", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.in, func(t *testing.T) {
+			require.Equal(t, c.snake, SnakeCaseNaming(c.in))
+			require.Equal(t, c.kebab, KebabCaseNaming(c.in))
+			require.Equal(t, c.in, IdentityNaming(c.in))
+			require.Equal(t, strings.ToLower(c.in), LowerNaming(c.in))
+		})
+	}
+}
+
+type namingConfig struct {
+	MaxConns   int
+	HTTPServer struct {
+		ReadTimeout int
+	}
+	Tagged string `starlark:"TaggedName"`
+}
+
+func TestNaming_RoundTrip(t *testing.T) {
+	in := namingConfig{MaxConns: 1, Tagged: "t"}
+	in.HTTPServer.ReadTimeout = 2
+
+	cases := []struct {
+		ns   NamingStrategy
+		keys []string
+	}{
+		{nil, []string{"HTTPServer", "MaxConns", "TaggedName"}},
+		{IdentityNaming, []string{"HTTPServer", "MaxConns", "TaggedName"}},
+		{LowerNaming, []string{"TaggedName", "httpserver", "maxconns"}},
+		{SnakeCaseNaming, []string{"TaggedName", "http_server", "max_conns"}},
+		{KebabCaseNaming, []string{"TaggedName", "http-server", "max-conns"}},
+		{func(s string) string { return "x" + s }, []string{"TaggedName", "xHTTPServer", "xMaxConns"}},
+	}
+	for _, c := range cases {
+		t.Run(strings.Join(c.keys, ","), func(t *testing.T) {
+			m := M{}
+			require.NoError(t, ToStarlark(in, m, FieldNamingTo(c.ns), EncodeStructsAs(StructAsDict)))
+			require.Equal(t, c.keys, starlark.StringDict(m).Keys())
+
+			var out namingConfig
+			require.NoError(t, FromStarlark(m, &out, FieldNamingFrom(c.ns)))
+			require.Equal(t, in, out)
+		})
+	}
+}
+
+func TestFieldNamingFrom(t *testing.T) {
+	// with a naming strategy, the lowercase field name is not matched
+	var out namingConfig
+	err := FromStarlark(M{"maxconns": starlark.MakeInt(1)}, &out, FieldNamingFrom(SnakeCaseNaming))
+	require.NoError(t, err)
+	require.Equal(t, 0, out.MaxConns)
+
+	// strict mode and suggestions use the naming strategy
+	err = FromStarlark(M{"max_con": starlark.MakeInt(1), "MaxConns": starlark.MakeInt(1)}, &out,
+		FieldNamingFrom(SnakeCaseNaming), DisallowUnknownKeys(nil))
+	require.EqualError(t, err, `unknown key "MaxConns" (did you mean "max_conns"?)`+"\n"+
+		`unknown key "max_con" (did you mean "max_conns"?)`)
+}
+
+func TestCaseInsensitiveKeys(t *testing.T) {
+	var out namingConfig
+	err := FromStarlark(M{
+		"MAX_CONNS":   starlark.MakeInt(1),
+		"taggedname":  starlark.String("t"),
+		"Http_Server": dict(M{"READ_TIMEOUT": starlark.MakeInt(2)}),
+	}, &out, FieldNamingFrom(SnakeCaseNaming), CaseInsensitiveKeys(), DisallowUnknownKeys(nil))
+	require.NoError(t, err)
+	require.Equal(t, 1, out.MaxConns)
+	require.Equal(t, "t", out.Tagged)
+	require.Equal(t, 2, out.HTTPServer.ReadTimeout)
+
+	out = namingConfig{}
+	err = FromStarlark(M{
+		"maxConns": starlark.MakeInt(1),
+		"MaxConns": starlark.MakeInt(2),
+		"Tagged":   starlark.String("t"),
+	}, &out, CaseInsensitiveKeys())
+	require.EqualError(t, err, `MaxConns: ambiguous keys "MaxConns", "maxConns" match the field`)
+	require.Equal(t, 0, out.MaxConns)
+
+	var ake *AmbiguousKeyError
+	require.ErrorAs(t, err, &ake)
+	require.Equal(t, "MaxConns", ake.Path)
+	require.Equal(t, []string{"MaxConns", "maxConns"}, ake.Keys)
+
+	// an ambiguous required field is not reported as missing
+	var req struct {
+		Name string `starlark:"name,required,default='x'"`
+	}
+	err = FromStarlark(M{"NAME": starlark.String("a"), "Name": starlark.String("b")}, &req, CaseInsensitiveKeys())
+	require.EqualError(t, err, `Name: ambiguous keys "NAME", "Name" match the field`)
+	require.Equal(t, "", req.Name)
+}
+
+func TestUnpackArgs_Naming(t *testing.T) {
+	var out namingConfig
+	err := UnpackArgs("fn", nil, []starlark.Tuple{{starlark.String("max_conns"), starlark.MakeInt(2)}}, &out, FieldNamingFrom(SnakeCaseNaming))
+	require.NoError(t, err)
+	require.Equal(t, 2, out.MaxConns)
+
+	err = UnpackArgs("fn", nil, []starlark.Tuple{{starlark.String("maxconns"), starlark.MakeInt(2)}}, &out, FieldNamingFrom(SnakeCaseNaming))
+	require.EqualError(t, err, `fn: unexpected keyword argument "maxconns"`)
+
+	c := NewCollector[namingConfig]("cfg", FieldNamingFrom(SnakeCaseNaming))
+	execScript(t, `cfg(max_conns=3)`, starlark.StringDict{"cfg": c.Builtin()})
+	got := c.Items()
+	require.Len(t, got, 1)
+	require.Equal(t, 3, got[0].MaxConns)
+}
+
+func TestBind_Naming(t *testing.T) {
+	var cfg namingConfig
+	b := Bind(&cfg, FieldNamingFrom(SnakeCaseNaming))
+	require.Equal(t, []string{"TaggedName", "http_server", "max_conns"}, b.(starlark.HasAttrs).AttrNames())
+
+	mod := execScript(t, `
+cfg.max_conns = 3
+cfg.http_server.read_timeout = cfg.max_conns + 1
+s = str(cfg.http_server)
+`, starlark.StringDict{"cfg": b})
+	require.Equal(t, 3, cfg.MaxConns)
+	require.Equal(t, 4, cfg.HTTPServer.ReadTimeout)
+	require.Equal(t, starlark.String(`{"read_timeout": 4}`), mod["s"])
+
+	// the lowercase field name is not matched
+	var th starlark.Thread
+	_, err := starlark.ExecFile(&th, "test", `cfg.maxconns = 1`, starlark.StringDict{"cfg": b})
+	require.ErrorContains(t, err, `has no .maxconns field`)
+}
+
+func TestConstructor_Naming(t *testing.T) {
+	ctor := Constructor(reflect.TypeOf(namingConfig{}), FieldNamingFrom(KebabCaseNaming))
+	mod := execScript(t, `
+cfg = namingConfig(**{"max-conns": 3})
+n = getattr(cfg, "max-conns")
+`, starlark.StringDict{"namingConfig": ctor})
+	require.Equal(t, starlark.MakeInt(3), mod["n"])
+}
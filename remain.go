@@ -0,0 +1,154 @@
+package starstruct
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// remain is the remain field of a struct, which holds the keys that do not
+// match any other field.
+type remain struct {
+	path  string
+	fld   reflect.Value
+	opts  tagOpt
+	fopts []fieldOpt
+}
+
+// returns true if the raw struct tag options have the remain option.
+func isRemainTag(rawOpts string) bool {
+	_, fopts := parseTagOpts(rawOpts)
+	_, ok := findFieldOpt(fopts, "remain")
+	return ok
+}
+
+// returns the remain field of the struct strct at path, looking into its
+// embedded structs (not pointers to structs) without a starlark name. It
+// returns false if there is none, and a TagError if there are many or if it
+// is not a map with string keys.
+func remainField(path string, strct reflect.Value) (remain, bool, error) {
+	var r remain
+	var found bool
+
+	strctTyp := strct.Type()
+	count := strctTyp.NumField()
+	for i := 0; i < count; i++ {
+		fldTyp := strctTyp.Field(i)
+		nm, rawOpts, _ := strings.Cut(fldTyp.Tag.Get("starlark"), ",")
+		if !fldTyp.IsExported() || nm == "-" {
+			continue
+		}
+
+		path := path
+		if path != "" {
+			path += "."
+		}
+		path += fldTyp.Name
+
+		var emb remain
+		var ok bool
+		switch {
+		case nm == "" && fldTyp.Anonymous && fldTyp.Type.Kind() == reflect.Struct:
+			var err error
+			if emb, ok, err = remainField(path, strct.Field(i)); err != nil {
+				return remain{}, false, err
+			}
+		case isRemainTag(rawOpts):
+			if !isRemainType(fldTyp.Type) {
+				return remain{}, false, &TagError{Path: path, Option: "remain", Err: errors.New("not a map with string keys")}
+			}
+			emb, ok = remain{path: path, fld: strct.Field(i)}, true
+			emb.opts, emb.fopts = parseTagOpts(rawOpts)
+		}
+		if !ok {
+			continue
+		}
+		if found {
+			return remain{}, false, &TagError{Path: emb.path, Option: "remain", Err: fmt.Errorf("more than one remain field, with %s", r.path)}
+		}
+		r, found = emb, true
+	}
+	return r, found, nil
+}
+
+// returns true if t is a map with string keys, or a pointer to such a map.
+func isRemainType(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String
+}
+
+// returns a Dict of the entries of dict with keys that do not match a field of
+// the struct strct (nor the ignored key, if any), or nil if there are none.
+// The keys that are not strings are skipped.
+func (d *decoder) remainingKeys(strct reflect.Value, dict dictGetSetter, ignoreKey string) *starlark.Dict {
+	keys := d.fieldKeys(strct.Type())
+
+	var rest *starlark.Dict
+	for _, k := range dictKeys(dict) {
+		s, ok := k.(starlark.String)
+		if !ok || string(s) == ignoreKey {
+			continue
+		}
+		if d.isFieldKey(keys, string(s)) {
+			continue
+		}
+		v, _, _ := dict.Get(k)
+		if rest == nil {
+			rest = starlark.NewDict(1)
+		}
+		_ = rest.SetKey(k, v) // cannot fail, key is a string
+	}
+	return rest
+}
+
+// decodes the remaining entries rest into the remain field r.
+func (d *decoder) setFieldRemain(r remain, rest *starlark.Dict) {
+	nerrs := len(d.errs)
+	d.setFieldMap(r.path, r.fld, rest, r.opts)
+	if len(d.errs) == nerrs {
+		d.validateField(r.path, r.fld, r.fopts)
+	}
+}
+
+// sets the entries of the remain field r of the struct strct in dst. It
+// records a KeyConflictError for each key that matches a field of the
+// struct.
+func (e *encoder) spreadRemain(r remain, strct reflect.Value, dst dictGetSetter) {
+	if e.validate {
+		e.validateField(r.path, r.fld, r.fopts)
+	}
+
+	m := r.fld
+	if m.Kind() == reflect.Pointer {
+		m = m.Elem()
+	}
+	if !m.IsValid() || m.IsNil() {
+		return
+	}
+
+	// only the keys encoded for the fields conflict, not the lowercase names
+	// that FromStarlark tries for the fields without a starlark name.
+	keys := structKeys(strct.Type(), e.naming, false, nil)
+	for _, k := range sortedMapKeys(m) {
+		path := fmt.Sprintf("%s[%v]", r.path, k)
+		if _, known := keys[k.String()]; known {
+			e.recordKeyConflictErr(path, k.String(), m.MapIndex(k))
+			continue
+		}
+		e.toStarlarkValue(path, k.String(), m.MapIndex(k), dst, r.opts.shift())
+	}
+}
+
+func (e *encoder) recordKeyConflictErr(path, key string, goVal reflect.Value) {
+	err := &KeyConflictError{
+		Path:  path,
+		Key:   key,
+		GoVal: goVal,
+	}
+	e.recordErr(err)
+}
@@ -0,0 +1,183 @@
+package starstruct
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.starlark.net/starlark"
+)
+
+type RemainBase struct {
+	Enabled bool `starlark:"enabled"`
+}
+
+type remainPlugins struct {
+	RemainBase
+	Name   string                    `starlark:"name"`
+	Others map[string]starlark.Value `starlark:",remain"`
+}
+
+type remainAny struct {
+	Name   string          `starlark:"name"`
+	Others *map[string]any `starlark:"others,remain,maxlen=2"`
+}
+
+func TestFromStarlark_Remain(t *testing.T) {
+	var out struct {
+		Plugins remainPlugins `starlark:"plugins"`
+		Any     remainAny     `starlark:"any"`
+	}
+	err := FromStarlark(M{
+		"plugins": dict(M{
+			"enabled": starlark.True,
+			"name":    starlark.String("p"),
+			"a":       starlark.MakeInt(1),
+			"b":       list(starlark.String("x")),
+		}),
+		"any": dict(M{
+			"name": starlark.String("n"),
+			"a":    starlark.MakeInt(1),
+		}),
+	}, &out)
+	require.NoError(t, err)
+	require.Equal(t, remainPlugins{
+		RemainBase: RemainBase{Enabled: true},
+		Name:       "p",
+		Others: map[string]starlark.Value{
+			"a": starlark.MakeInt(1),
+			"b": list(starlark.String("x")),
+		},
+	}, out.Plugins)
+	require.Equal(t, "n", out.Any.Name)
+	require.Equal(t, map[string]any{"a": int64(1)}, *out.Any.Others)
+
+	// no remaining key leaves the field unmodified
+	var p remainPlugins
+	require.NoError(t, FromStarlark(M{"name": starlark.String("p")}, &p))
+	require.Nil(t, p.Others)
+
+	// the remain field is validated
+	var a remainAny
+	err = FromStarlark(M{"a": starlark.None, "b": starlark.None, "c": starlark.None}, &a)
+	require.EqualError(t, err, "Others: value map[a:<nil> b:<nil> c:<nil>] does not satisfy maxlen=2")
+}
+
+func TestFromStarlark_RemainStrict(t *testing.T) {
+	d := dict(M{"name": starlark.String("p"), "x": starlark.None})
+	require.NoError(t, d.SetKey(starlark.MakeInt(1), starlark.None))
+
+	var out struct {
+		Plugins remainPlugins `starlark:"plugins"`
+	}
+	err := FromStarlark(M{"plugins": d}, &out, DisallowUnknownKeys(nil))
+	require.EqualError(t, err, "Plugins: unknown key 1")
+	require.Equal(t, map[string]starlark.Value{"x": starlark.None}, out.Plugins.Others)
+}
+
+func TestToStarlark_Remain(t *testing.T) {
+	in := remainPlugins{
+		RemainBase: RemainBase{Enabled: true},
+		Name:       "p",
+		Others: map[string]starlark.Value{
+			"b": starlark.MakeInt(2),
+			"a": starlark.MakeInt(1),
+		},
+	}
+	m := M{}
+	require.NoError(t, ToStarlark(in, m))
+	require.Equal(t, M{
+		"enabled": starlark.True,
+		"name":    starlark.String("p"),
+		"a":       starlark.MakeInt(1),
+		"b":       starlark.MakeInt(2),
+	}, m)
+
+	// round-trip
+	var out remainPlugins
+	require.NoError(t, FromStarlark(m, &out))
+	require.Equal(t, in, out)
+
+	// conflicts with the fields are reported
+	in.Others["name"] = starlark.String("x")
+	in.Others["enabled"] = starlark.False
+	m = M{}
+	err := ToStarlark(in, m)
+	require.EqualError(t, err, `Others[enabled]: key "enabled" conflicts with a struct field`+"\n"+
+		`Others[name]: key "name" conflicts with a struct field`)
+	var kce *KeyConflictError
+	require.ErrorAs(t, err, &kce)
+	require.Equal(t, "enabled", kce.Key)
+	require.Equal(t, starlark.String("p"), m["name"])
+
+	// the lowercase name of a field without a starlark name does not conflict,
+	// as it is not encoded
+	m = M{}
+	err = ToStarlark(struct {
+		Port   int
+		Others map[string]int `starlark:",remain"`
+	}{Port: 1, Others: map[string]int{"port": 2}}, m)
+	require.NoError(t, err)
+	require.Equal(t, M{"Port": starlark.MakeInt(1), "port": starlark.MakeInt(2)}, m)
+
+	// nil remain field
+	m = M{}
+	require.NoError(t, ToStarlark(remainAny{Name: "n"}, m))
+	require.Equal(t, M{"name": starlark.String("n")}, m)
+}
+
+func TestUnpackArgs_Remain(t *testing.T) {
+	var args struct {
+		Name   string         `starlark:"name"`
+		Kwargs map[string]int `starlark:",remain"`
+	}
+	err := UnpackArgs("fn", starlark.Tuple{starlark.String("n")}, []starlark.Tuple{
+		{starlark.String("a"), starlark.MakeInt(1)},
+		{starlark.String("Kwargs"), starlark.MakeInt(2)},
+	}, &args)
+	require.NoError(t, err)
+	require.Equal(t, "n", args.Name)
+	require.Equal(t, map[string]int{"a": 1, "Kwargs": 2}, args.Kwargs)
+
+	err = UnpackArgs("fn", nil, []starlark.Tuple{{starlark.String("a"), starlark.String("x")}}, &args)
+	require.EqualError(t, err, `fn: Kwargs["a"]: cannot convert Starlark string to Go type int`)
+}
+
+func TestRemain_Invalid(t *testing.T) {
+	type notMap struct {
+		Name   string
+		Others []string `starlark:",remain"`
+	}
+	type RemainBase struct {
+		Others map[string]any `starlark:",remain"`
+	}
+	type twoRemains struct {
+		RemainBase
+		Others map[string]any `starlark:",remain"`
+	}
+
+	t.Run("not a map", func(t *testing.T) {
+		var out notMap
+		err := FromStarlark(M{"Name": starlark.String("a")}, &out)
+		require.EqualError(t, err, "Others: invalid tag option remain: not a map with string keys")
+		var te *TagError
+		require.ErrorAs(t, err, &te)
+		require.Equal(t, "a", out.Name)
+	})
+
+	t.Run("more than one", func(t *testing.T) {
+		err := ToStarlark(twoRemains{}, make(starlark.StringDict))
+		require.EqualError(t, err, "Others: invalid tag option remain: more than one remain field, with RemainBase.Others")
+	})
+
+	t.Run("unpack args", func(t *testing.T) {
+		var args notMap
+		err := UnpackArgs("fn", nil, nil, &args)
+		require.EqualError(t, err, "fn: Others: invalid tag option remain: not a map with string keys")
+	})
+
+	t.Run("checked on creation", func(t *testing.T) {
+		require.PanicsWithValue(t, "invalid struct tag in type starstruct.twoRemains: Others: invalid tag option remain: more than one remain field, with RemainBase.Others", func() {
+			Bind(&twoRemains{})
+		})
+	})
+}
@@ -3,11 +3,14 @@ package starstruct_test
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/mna/starstruct"
 	"github.com/stretchr/testify/require"
+	startime "go.starlark.net/lib/time"
 	"go.starlark.net/resolve"
 	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
 )
 
 // This file contains "end-to-end" kinds of tests, where ToStarlark is used to
@@ -140,6 +143,107 @@ x = {
 	require.Equal(t, want, out)
 }
 
+func TestTupleKeys(t *testing.T) {
+	const script = `
+routes[(443, "tcp")] = "https"
+ports[8080] = ports.pop(80)
+`
+
+	type Route struct {
+		Port  int
+		Proto string
+	}
+	type S struct {
+		Routes map[Route]string `starlark:"routes"`
+		Ports  map[uint16]bool  `starlark:"ports,asdict"`
+	}
+
+	globals := make(starlark.StringDict)
+	in := S{
+		Routes: map[Route]string{{80, "tcp"}: "http"},
+		Ports:  map[uint16]bool{80: true},
+	}
+	require.NoError(t, starstruct.ToStarlark(in, globals))
+
+	var th starlark.Thread
+	mod, err := starlark.ExecFile(&th, "test", script, globals)
+	require.NoError(t, err)
+	mergeStringDicts(globals, mod)
+
+	var out S
+	require.NoError(t, starstruct.FromStarlark(globals, &out))
+	require.Equal(t, S{
+		Routes: map[Route]string{{80, "tcp"}: "http", {443, "tcp"}: "https"},
+		Ports:  map[uint16]bool{8080: true},
+	}, out)
+}
+
+func TestTimeValues(t *testing.T) {
+	const script = `
+timeout = timeout * 2
+deadline = start + time.parse_duration("1h30m")
+retry = "%ds" % (timeout // time.second)
+`
+
+	type S struct {
+		Start    time.Time     `starlark:"start"`
+		Deadline time.Time     `starlark:"deadline"`
+		Timeout  time.Duration `starlark:"timeout"`
+		Retry    time.Duration `starlark:"retry,asstring"`
+	}
+
+	globals := starlark.StringDict{"time": startime.Module}
+	start := time.Date(2023, 2, 5, 16, 0, 0, 0, time.UTC)
+	in := S{Start: start, Timeout: 5 * time.Second}
+	require.NoError(t, starstruct.ToStarlark(in, globals))
+
+	var th starlark.Thread
+	mod, err := starlark.ExecFile(&th, "test", script, globals)
+	require.NoError(t, err)
+	mergeStringDicts(globals, mod)
+
+	var out S
+	require.NoError(t, starstruct.FromStarlark(globals, &out))
+	require.Equal(t, S{
+		Start:    start,
+		Deadline: start.Add(90 * time.Minute),
+		Timeout:  10 * time.Second,
+		Retry:    10 * time.Second,
+	}, out)
+}
+
+func TestStructAttrs(t *testing.T) {
+	const script = `
+server = struct(addr = server.addr, port = server.port + 1)
+listen = "%s:%d" % (server.addr, server.port)
+`
+
+	type Server struct {
+		Addr string `starlark:"addr"`
+		Port int    `starlark:"port"`
+	}
+	type S struct {
+		Server Server `starlark:"server,asstruct"`
+		Listen string `starlark:"listen"`
+	}
+
+	globals := starlark.StringDict{"struct": starlark.NewBuiltin("struct", starlarkstruct.Make)}
+	in := S{Server: Server{Addr: "localhost", Port: 8080}}
+	require.NoError(t, starstruct.ToStarlark(in, globals))
+
+	var th starlark.Thread
+	mod, err := starlark.ExecFile(&th, "test", script, globals)
+	require.NoError(t, err)
+	mergeStringDicts(globals, mod)
+
+	var out S
+	require.NoError(t, starstruct.FromStarlark(globals, &out))
+	require.Equal(t, S{
+		Server: Server{Addr: "localhost", Port: 8081},
+		Listen: "localhost:8081",
+	}, out)
+}
+
 func mergeStringDicts(dst starlark.StringDict, vs ...starlark.StringDict) starlark.StringDict {
 	if dst == nil {
 		dst = make(starlark.StringDict)
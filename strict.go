@@ -0,0 +1,200 @@
+package starstruct
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// DisallowUnknownKeys reports the keys of the starlark values that do not
+// match any field of the struct they are decoded into, including the
+// top-level globals, as UnknownKeyError errors. Keys that are not strings,
+// which are otherwise ignored, are reported too.
+//
+// The allowGlobal predicate, if not nil, is called for each top-level global
+// that does not match a field of the destination struct, and the global is
+// not reported if it returns true. It can be used to allow the script to
+// define helper functions or load modules, e.g. by returning true for
+// starlark.Callable values.
+func DisallowUnknownKeys(allowGlobal func(name string, v starlark.Value) bool) FromOption {
+	return func(d *decoder) {
+		d.strict = true
+		d.allowGlobal = allowGlobal
+	}
+}
+
+// records an UnknownKeyError for each key of dict that does not match a field
+// of the struct strct at path. If the struct has a remain field, only the
+// keys that are not strings are unknown.
+func (d *decoder) checkUnknownKeys(path string, strct reflect.Value, dict dictGetSetter, ignoreKey string, hasRemain bool) {
+	keys := d.fieldKeys(strct.Type())
+
+	for _, k := range dictKeys(dict) {
+		s, ok := k.(starlark.String)
+		if ok {
+			if d.isFieldKey(keys, string(s)) || hasRemain || string(s) == ignoreKey {
+				continue
+			}
+			if sd, isGlobals := dict.(stringDictValue); isGlobals && path == "" && d.allowGlobal != nil {
+				if d.allowGlobal(string(s), sd.StringDict[string(s)]) {
+					continue
+				}
+			}
+		}
+
+		var suggest []string
+		if ok {
+			suggest = suggestKeys(string(s), keys)
+		}
+		d.recordUnknownKeyErr(path, k, suggest)
+	}
+}
+
+// returns the starlark keys that match the fields of the struct type typ with
+// the naming strategy ns, added to keys, mapped to the key to suggest for that
+// field. If lower is true, the lowercase names that are tried for the fields
+// without a starlark name are included, otherwise only the keys that
+// ToStarlark encodes are.
+func structKeys(typ reflect.Type, ns NamingStrategy, lower bool, keys map[string]string) map[string]string {
+	if keys == nil {
+		keys = make(map[string]string)
+	}
+	count := typ.NumField()
+	for i := 0; i < count; i++ {
+		fldTyp := typ.Field(i)
+		nm, rawOpts, _ := strings.Cut(fldTyp.Tag.Get("starlark"), ",")
+		if !fldTyp.IsExported() || nm == "-" || isRemainTag(rawOpts) {
+			continue
+		}
+		if nm == "" {
+			if fldTyp.Anonymous {
+				embTyp := fldTyp.Type
+				if embTyp.Kind() == reflect.Pointer {
+					embTyp = embTyp.Elem()
+				}
+				if embTyp.Kind() == reflect.Struct {
+					structKeys(embTyp, ns, lower, keys)
+				}
+				continue
+			}
+			var tryLower bool
+			nm, tryLower = fieldKey(ns, fldTyp.Name)
+			if tryLower && lower {
+				keys[strings.ToLower(nm)] = nm
+			}
+		}
+		keys[nm] = nm
+	}
+	return keys
+}
+
+// returns the keys of the dict, in iteration order (sorted for a StringDict).
+func dictKeys(dict dictGetSetter) []starlark.Value {
+	switch dict := dict.(type) {
+	case stringDictValue:
+		var keys []starlark.Value
+		for _, k := range dict.Keys() {
+			keys = append(keys, starlark.String(k))
+		}
+		return keys
+	case attrsValue:
+		var keys []starlark.Value
+		for _, k := range dict.AttrNames() {
+			keys = append(keys, starlark.String(k))
+		}
+		return keys
+	case *starlark.Dict:
+		return dict.Keys()
+	case mappingValue:
+		var keys []starlark.Value
+		for _, kv := range dict.Items() {
+			keys = append(keys, kv[0])
+		}
+		return keys
+	}
+	return nil
+}
+
+// maximum number of suggestions for an unknown key.
+const maxSuggestions = 3
+
+// returns the keys to suggest for the unknown key, the closest ones by edit
+// distance (case-insensitive) first.
+func suggestKeys(key string, keys map[string]string) []string {
+	type candidate struct {
+		name string
+		dist int
+	}
+
+	maxDist := len(key) / 2
+	if maxDist < 1 {
+		maxDist = 1
+	}
+
+	seen := make(map[string]bool)
+	var cands []candidate
+	for _, nm := range keys {
+		if seen[nm] {
+			continue
+		}
+		seen[nm] = true
+		if dist := editDistance(strings.ToLower(key), strings.ToLower(nm)); dist <= maxDist {
+			cands = append(cands, candidate{name: nm, dist: dist})
+		}
+	}
+	sort.Slice(cands, func(i, j int) bool {
+		if cands[i].dist != cands[j].dist {
+			return cands[i].dist < cands[j].dist
+		}
+		return cands[i].name < cands[j].name
+	})
+
+	var names []string
+	for i := 0; i < len(cands) && i < maxSuggestions; i++ {
+		names = append(names, cands[i].name)
+	}
+	return names
+}
+
+// returns the Levenshtein distance between a and b, in runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(vals ...int) int {
+	m := vals[0]
+	for _, v := range vals[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func (d *decoder) recordUnknownKeyErr(path string, key starlark.Value, suggest []string) {
+	err := &UnknownKeyError{
+		Path:        path,
+		Key:         key,
+		Suggestions: suggest,
+	}
+	d.recordErr(err)
+}
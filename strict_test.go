@@ -0,0 +1,108 @@
+package starstruct
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+type StrictBase struct {
+	Debug bool `starlark:"debug"`
+}
+
+type strictConfig struct {
+	StrictBase
+	Server struct {
+		Name string `starlark:"name"`
+		Port int    `starlark:"port"`
+	} `starlark:"server"`
+	Admins  []string
+	Ignored int `starlark:"-"`
+	Auth    auth
+}
+
+func TestFromStarlark_DisallowUnknownKeys(t *testing.T) {
+	nonStrKey := starlark.NewDict(1)
+	require.NoError(t, nonStrKey.SetKey(starlark.MakeInt(1), starlark.True))
+
+	cases := []struct {
+		name string
+		vals M
+		err  string
+	}{
+		{"known", M{
+			"debug":  starlark.True,
+			"server": dict(M{"name": starlark.String("a"), "port": starlark.MakeInt(1)}),
+			"admins": list(),
+			"Admins": list(),
+			"Auth":   dict(M{"kind": starlark.String("basic"), "user": starlark.String("u")}),
+		}, ``},
+		{"global typo", M{"srever": dict(M{})}, `unknown key "srever" (did you mean "server"?)`},
+		{"dict typo", M{"server": dict(M{"nmae": starlark.String("a")})}, `Server: unknown key "nmae" (did you mean "name"?)`},
+		{"many suggestions", M{"debog": starlark.True, "bebug": starlark.True}, `unknown key "bebug" (did you mean "debug"?)` + "\n" + `unknown key "debog" (did you mean "debug"?)`},
+		{"case", M{"ADMINS": list()}, `unknown key "ADMINS" (did you mean "Admins"?)`},
+		{"ignored field", M{"Ignored": starlark.MakeInt(1)}, `unknown key "Ignored"`},
+		{"no suggestion", M{"xyz": starlark.None}, `unknown key "xyz"`},
+		{"non-string key", M{"server": nonStrKey}, `Server: unknown key 1`},
+		{"union", M{"Auth": dict(M{"kind": starlark.String("basic"), "usr": starlark.String("u")})}, `Auth: unknown key "usr" (did you mean "user"?)`},
+		{"struct", M{"server": starlarkstruct.FromStringDict(starlarkstruct.Default, M{"prot": starlark.MakeInt(1)})}, `Server: unknown key "prot" (did you mean "port"?)`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var out strictConfig
+			err := FromStarlark(c.vals, &out, DisallowUnknownKeys(nil), UnionFromRegistry(newAuthRegistry()))
+			if c.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, c.err)
+			var uke *UnknownKeyError
+			require.ErrorAs(t, err, &uke)
+		})
+	}
+
+	t.Run("lenient by default", func(t *testing.T) {
+		var out strictConfig
+		err := FromStarlark(M{"srever": dict(M{}), "server": dict(M{"nmae": starlark.None})}, &out)
+		require.NoError(t, err)
+	})
+}
+
+func TestFromStarlark_DisallowUnknownKeysAllowGlobal(t *testing.T) {
+	mod := execScript(t, `
+def helper(n):
+	return n * 2
+
+server = {"port": helper(4)}
+other = 1
+`, nil)
+
+	allow := func(name string, v starlark.Value) bool {
+		_, ok := v.(starlark.Callable)
+		return ok
+	}
+
+	var out strictConfig
+	err := FromStarlark(mod, &out, DisallowUnknownKeys(allow))
+	require.EqualError(t, err, `unknown key "other"`)
+	require.Equal(t, 8, out.Server.Port)
+
+	var uke *UnknownKeyError
+	require.ErrorAs(t, err, &uke)
+	require.Equal(t, "", uke.Path)
+	require.Equal(t, starlark.String("other"), uke.Key)
+	require.Empty(t, uke.Suggestions)
+}
+
+func TestSuggestKeys(t *testing.T) {
+	keys := map[string]string{"name": "name", "names": "names", "port": "port", "Admins": "Admins", "admins": "Admins"}
+	require.Equal(t, []string{"name", "names"}, suggestKeys("namez", keys))
+	require.Equal(t, []string{"name"}, suggestKeys("nmae", keys))
+	require.Equal(t, []string{"Admins"}, suggestKeys("admin", keys))
+	require.Empty(t, suggestKeys("x", keys))
+	require.Equal(t, 3, editDistance("kitten", "sitting"))
+	require.Equal(t, 0, editDistance("", ""))
+}
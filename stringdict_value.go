@@ -6,7 +6,11 @@ import (
 	"go.starlark.net/starlark"
 )
 
-var _ = dictGetSetter((*stringDictValue)(nil))
+var (
+	_ = dictGetSetter((*stringDictValue)(nil))
+	_ = dictGetSetter((*attrsValue)(nil))
+	_ = dictGetSetter((*mappingValue)(nil))
+)
 
 type dictGetSetter interface {
 	starlark.Value
@@ -37,3 +41,36 @@ func (v stringDictValue) SetKey(k, x starlark.Value) error {
 	v.StringDict[string(s)] = x
 	return nil
 }
+
+// attrsValue wraps a starlark value with attributes (such as a
+// starlarkstruct.Struct or Module) so that it can be decoded into a struct as
+// if it was a dictionary. It is read-only.
+type attrsValue struct {
+	starlark.HasAttrs
+}
+
+func (v attrsValue) Get(k starlark.Value) (starlark.Value, bool, error) {
+	s, ok := k.(starlark.String)
+	if !ok {
+		return nil, false, errors.New("attrsValue key is not a string")
+	}
+	// an attribute that fails to resolve is treated as missing
+	x, err := v.Attr(string(s))
+	if err != nil || x == nil {
+		return nil, false, nil
+	}
+	return x, true, nil
+}
+func (v attrsValue) SetKey(k, x starlark.Value) error {
+	return errors.New("attrsValue is read-only")
+}
+
+// mappingValue wraps a starlark.IterableMapping (such as a custom mapping
+// type) so that it can be decoded into a struct or a map. It is read-only.
+type mappingValue struct {
+	starlark.IterableMapping
+}
+
+func (v mappingValue) SetKey(k, x starlark.Value) error {
+	return errors.New("mappingValue is read-only")
+}
@@ -0,0 +1,205 @@
+package starstruct
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	startime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// units supported by the asint tag option for time values.
+var timeUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// returns the unit of the asint tag option opt, which may specify a unit in
+// the form "asint=ms", or def if it does not specify one. It returns false
+// if opt is not an asint tag option, and an error if the unit is invalid.
+func timeIntUnit(opt string, def time.Duration) (time.Duration, bool, error) {
+	name, unit, hasUnit := strings.Cut(opt, "=")
+	if name != "asint" {
+		return 0, false, nil
+	}
+	if !hasUnit {
+		return def, true, nil
+	}
+	u, ok := timeUnits[unit]
+	if !ok {
+		return 0, true, fmt.Errorf("invalid time unit %q", unit)
+	}
+	return u, true, nil
+}
+
+func isTOrPtrTTimeType(t reflect.Type) bool {
+	return isTOrPtrTType(t, timeType) || isTOrPtrTType(t, durationType)
+}
+
+// converts the time.Time value goVal to a starlark lib/time Time, or to a
+// String or Int depending on the opt tag option.
+func (e *encoder) convertTime(path string, goVal reflect.Value, opt string) starlark.Value {
+	t := goVal.Interface().(time.Time)
+	if opt == "asstring" {
+		return starlark.String(t.Format(time.RFC3339Nano))
+	}
+	unit, ok, err := timeIntUnit(opt, time.Second)
+	if err != nil {
+		e.recordErr(&TagError{Path: path, Option: opt, Err: err})
+		return starlark.None
+	}
+	if ok {
+		// number of units since the Unix epoch, computed with arbitrary precision
+		// as it may overflow an int64 number of nanoseconds.
+		ns := new(big.Int).Mul(big.NewInt(t.Unix()), big.NewInt(int64(time.Second)))
+		ns.Add(ns, big.NewInt(int64(t.Nanosecond())))
+		q, m := new(big.Int).DivMod(ns, big.NewInt(int64(unit)), new(big.Int))
+		if m.Sign() != 0 {
+			e.recordNumberErr(path, starlark.MakeBigInt(q), goVal, NumCannotExactlyRepresent)
+			return starlark.None
+		}
+		return starlark.MakeBigInt(q)
+	}
+	return startime.Time(t)
+}
+
+// converts the time.Duration value goVal to a starlark lib/time Duration, or
+// to a String or Int depending on the opt tag option.
+func (e *encoder) convertDuration(path string, goVal reflect.Value, opt string) starlark.Value {
+	d := time.Duration(goVal.Int())
+	if opt == "asstring" {
+		return starlark.String(d.String())
+	}
+	unit, ok, err := timeIntUnit(opt, time.Nanosecond)
+	if err != nil {
+		e.recordErr(&TagError{Path: path, Option: opt, Err: err})
+		return starlark.None
+	}
+	if ok {
+		if d%unit != 0 {
+			e.recordNumberErr(path, starlark.MakeInt64(int64(d/unit)), goVal, NumCannotExactlyRepresent)
+			return starlark.None
+		}
+		return starlark.MakeInt64(int64(d / unit))
+	}
+	return startime.Duration(d)
+}
+
+func (d *decoder) setFieldTime(path string, fld reflect.Value, v starlark.Value, opt string) {
+	// an invalid unit is an error, regardless of the starlark value
+	if _, _, err := timeIntUnit(opt, 0); err != nil {
+		d.recordErr(&TagError{Path: path, Option: opt, Err: err})
+		return
+	}
+
+	typ := fld.Type()
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	var newVal any
+	var ok bool
+	if typ == durationType {
+		newVal, ok = d.durationValue(path, v, fld, opt)
+	} else {
+		newVal, ok = d.timeValue(path, v, fld, opt)
+	}
+	if !ok {
+		return
+	}
+
+	// support a single-level of indirection, in case the value may be None
+	if fld.Kind() == reflect.Pointer {
+		if fld.IsNil() {
+			// allocate the *time.Time or *time.Duration value
+			fld.Set(reflect.New(typ))
+		}
+		fld = fld.Elem()
+	}
+	fld.Set(reflect.ValueOf(newVal))
+}
+
+// returns the time.Time decoded from the starlark value v. A String is parsed
+// as RFC 3339 and an Int is a number of units since the Unix epoch, in
+// seconds unless the opt tag option specifies another unit.
+func (d *decoder) timeValue(path string, v starlark.Value, fld reflect.Value, opt string) (time.Time, bool) {
+	switch v := v.(type) {
+	case startime.Time:
+		return time.Time(v), true
+
+	case starlark.String:
+		t, err := time.Parse(time.RFC3339, string(v))
+		if err != nil {
+			d.recordParseErr(path, v, fld, err)
+			return time.Time{}, false
+		}
+		return t, true
+
+	case starlark.Int:
+		// the unit is validated by setFieldTime
+		unit, ok, _ := timeIntUnit(opt, time.Second)
+		if !ok {
+			unit = time.Second
+		}
+		ns := new(big.Int).Mul(v.BigInt(), big.NewInt(int64(unit)))
+		sec, nsec := new(big.Int).DivMod(ns, big.NewInt(int64(time.Second)), new(big.Int))
+		if !sec.IsInt64() {
+			d.recordNumberErr(path, v, fld, NumOutOfRange)
+			return time.Time{}, false
+		}
+		return time.Unix(sec.Int64(), nsec.Int64()).UTC(), true
+
+	default:
+		d.recordTypeErr(path, v, fld)
+		return time.Time{}, false
+	}
+}
+
+// returns the time.Duration decoded from the starlark value v. A String is
+// parsed with time.ParseDuration and an Int is a number of nanoseconds unless
+// the opt tag option specifies another unit.
+func (d *decoder) durationValue(path string, v starlark.Value, fld reflect.Value, opt string) (time.Duration, bool) {
+	switch v := v.(type) {
+	case startime.Duration:
+		return time.Duration(v), true
+
+	case starlark.String:
+		dur, err := time.ParseDuration(string(v))
+		if err != nil {
+			d.recordParseErr(path, v, fld, err)
+			return 0, false
+		}
+		return dur, true
+
+	case starlark.Int:
+		// the unit is validated by setFieldTime
+		unit, ok, _ := timeIntUnit(opt, time.Nanosecond)
+		if !ok {
+			unit = time.Nanosecond
+		}
+		i, ok := v.Int64()
+		if !ok || i > math.MaxInt64/int64(unit) || i < math.MinInt64/int64(unit) {
+			d.recordNumberErr(path, v, fld, NumOutOfRange)
+			return 0, false
+		}
+		return time.Duration(i) * unit, true
+
+	default:
+		d.recordTypeErr(path, v, fld)
+		return 0, false
+	}
+}
@@ -0,0 +1,212 @@
+package starstruct
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// UnionFromRegistry sets the registry of discriminated unions to use when
+// decoding a starlark Dict into an interface value. See UnionRegistry for
+// details.
+func UnionFromRegistry(r *UnionRegistry) FromOption {
+	return func(d *decoder) {
+		d.unions = r
+	}
+}
+
+// UnionToRegistry sets the registry of discriminated unions to use when
+// encoding an interface value to a starlark Dict. See UnionRegistry for
+// details.
+func UnionToRegistry(r *UnionRegistry) ToOption {
+	return func(e *encoder) {
+		e.unions = r
+	}
+}
+
+// UnionRegistry holds the discriminated unions known to the encoder and
+// decoder. A discriminated union is an interface type whose concrete values
+// are represented in starlark as a Dict with a discriminator key, the value
+// of which identifies the concrete Go type (the "kind").
+//
+// For example, with an Auth interface registered with the "kind"
+// discriminator key and the kinds "basic" and "oauth" mapped to *BasicAuth
+// and *OAuth, a starlark Dict {"kind": "oauth", ...} decodes into an Auth
+// field as a new *OAuth value, the Dict being decoded into that value as if
+// it was a struct field. The discriminator key itself is only decoded if a
+// field of the concrete type maps to it.
+//
+// When encoding, the discriminator key is inserted first in the Dict
+// converted from an interface value if its dynamic type is registered, so
+// that it round-trips through ToStarlark and FromStarlark. If the value is
+// converted to a starlarkstruct.Struct or Module instead (see
+// EncodeStructsAs), the discriminator key is added to its attributes.
+//
+// A Dict (or a struct-like value with attributes, such as a
+// starlarkstruct.Struct) without the discriminator key or with an
+// unregistered kind cannot be decoded into a registered interface, and a
+// UnionError is recorded. Other values are decoded following the standard
+// rules for interfaces.
+type UnionRegistry struct {
+	unions map[reflect.Type]*union
+}
+
+type union struct {
+	key   string
+	kinds map[string]reflect.Type
+	names map[reflect.Type]string
+}
+
+// NewUnionRegistry returns a new, empty UnionRegistry.
+func NewUnionRegistry() *UnionRegistry {
+	return &UnionRegistry{unions: make(map[reflect.Type]*union)}
+}
+
+// Register registers the interface type iface as a discriminated union
+// identified by the starlark Dict key. The kinds map the values of the
+// discriminator key to the concrete Go types, which should be structs or
+// pointers to structs.
+//
+// It panics if iface is not an interface type or is already registered, if
+// key is empty, if a concrete type does not implement iface or if the same
+// concrete type is registered for more than one kind.
+func (r *UnionRegistry) Register(iface reflect.Type, key string, kinds map[string]reflect.Type) {
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("union type is not an interface: %s", iface))
+	}
+	if _, ok := r.unions[iface]; ok {
+		panic(fmt.Sprintf("union type is already registered: %s", iface))
+	}
+	if key == "" {
+		panic(fmt.Sprintf("union discriminator key is empty: %s", iface))
+	}
+
+	u := &union{
+		key:   key,
+		kinds: make(map[string]reflect.Type, len(kinds)),
+		names: make(map[reflect.Type]string, len(kinds)),
+	}
+	for kind, typ := range kinds {
+		if !typ.Implements(iface) {
+			panic(fmt.Sprintf("union kind %q: type %s does not implement %s", kind, typ, iface))
+		}
+		if other, ok := u.names[typ]; ok {
+			panic(fmt.Sprintf("union kind %q: type %s is already registered as kind %q", kind, typ, other))
+		}
+		u.kinds[kind] = typ
+		u.names[typ] = kind
+	}
+	r.unions[iface] = u
+}
+
+func (r *UnionRegistry) lookup(iface reflect.Type) *union {
+	if r == nil {
+		return nil
+	}
+	return r.unions[iface]
+}
+
+// returns the sorted list of kinds of the union.
+func (u *union) allowed() []string {
+	kinds := make([]string, 0, len(u.kinds))
+	for k := range u.kinds {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// returns the new Go value decoded from the starlark dict, based on its
+// discriminator value. It returns false if the value could not be decoded.
+func (d *decoder) unionValue(path string, u *union, dict dictGetSetter, goVal reflect.Value) (reflect.Value, bool) {
+	kind, ok, _ := dict.Get(starlark.String(u.key))
+	if !ok {
+		d.recordUnionErr(path, dict, goVal, u, nil)
+		return reflect.Value{}, false
+	}
+	s, ok := kind.(starlark.String)
+	if !ok {
+		d.recordUnionErr(path, dict, goVal, u, kind)
+		return reflect.Value{}, false
+	}
+	typ, ok := u.kinds[string(s)]
+	if !ok {
+		d.recordUnionErr(path, dict, goVal, u, kind)
+		return reflect.Value{}, false
+	}
+
+	// always allocate the pointed-to value, even if no field is set
+	newVal := reflect.New(typ).Elem()
+	target := newVal
+	if typ.Kind() == reflect.Pointer {
+		newVal = reflect.New(typ.Elem())
+		target = newVal.Elem()
+	}
+	d.discriminator = u.key
+	d.fromStarlarkValue(path, dict, target, nil)
+	d.discriminator = ""
+	return newVal, true
+}
+
+func (d *decoder) recordUnionErr(path string, starVal starlark.Value, goVal reflect.Value, u *union, kind starlark.Value) {
+	err := &UnionError{
+		Path:    path,
+		StarVal: starVal,
+		GoVal:   goVal,
+		Key:     u.key,
+		Kind:    kind,
+		Allowed: u.allowed(),
+	}
+	d.recordErr(err)
+}
+
+// adds the discriminator key for the dynamic type of goVal to the starlark
+// value sval if it is a Dict, Struct or Module and the type is registered in
+// the union.
+func (e *encoder) unionValue(path string, u *union, goVal reflect.Value, sval starlark.Value) starlark.Value {
+	kind, ok := u.names[goVal.Type()]
+	if !ok {
+		return sval
+	}
+
+	var dict *starlark.Dict
+	switch sval := sval.(type) {
+	case *starlark.Dict:
+		dict = sval
+	case *starlarkstruct.Struct:
+		members := make(starlark.StringDict)
+		sval.ToStringDict(members)
+		members[u.key] = starlark.String(kind)
+		return starlarkstruct.FromStringDict(sval.Constructor(), members)
+	case *starlarkstruct.Module:
+		members := make(starlark.StringDict, len(sval.Members)+1)
+		for k, v := range sval.Members {
+			members[k] = v
+		}
+		members[u.key] = starlark.String(kind)
+		return &starlarkstruct.Module{Name: sval.Name, Members: members}
+	default:
+		return sval
+	}
+
+	// insert the discriminator first, so it appears first when the Dict is
+	// printed or iterated.
+	key := starlark.String(u.key)
+	newDict := starlark.NewDict(dict.Len() + 1)
+	if err := newDict.SetKey(key, starlark.String(kind)); err != nil {
+		e.recordStarContainerErr(path, newDict, key, starlark.String(kind), goVal, err)
+	}
+	for _, kv := range dict.Items() {
+		if kv[0] == starlark.Value(key) {
+			// the discriminator takes precedence
+			continue
+		}
+		if err := newDict.SetKey(kv[0], kv[1]); err != nil {
+			e.recordStarContainerErr(path, newDict, kv[0], kv[1], goVal, err)
+		}
+	}
+	return newDict
+}
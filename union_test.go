@@ -0,0 +1,178 @@
+package starstruct
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+type auth interface{ isAuth() }
+
+type basicAuth struct {
+	User string `starlark:"user"`
+	Pwd  string `starlark:"password"`
+}
+
+type oauth struct {
+	Token  string   `starlark:"token"`
+	Scopes []string `starlark:"scopes"`
+}
+
+type mtls struct{}
+
+func (*basicAuth) isAuth() {}
+func (oauth) isAuth()      {}
+func (*mtls) isAuth()      {}
+
+func newAuthRegistry() *UnionRegistry {
+	reg := NewUnionRegistry()
+	reg.Register(reflect.TypeOf((*auth)(nil)).Elem(), "kind", map[string]reflect.Type{
+		"basic": reflect.TypeOf((*basicAuth)(nil)),
+		"oauth": reflect.TypeOf(oauth{}),
+		"mtls":  reflect.TypeOf((*mtls)(nil)),
+	})
+	return reg
+}
+
+func TestUnionFromStarlark(t *testing.T) {
+	type S struct {
+		Auth    auth
+		AuthPtr *auth
+		Auths   []auth
+		Any     any
+	}
+
+	cases := []struct {
+		name string
+		vals M
+		want S
+		err  string
+	}{
+		{"basic", M{"Auth": dict(M{"kind": starlark.String("basic"), "user": starlark.String("u")})}, S{Auth: &basicAuth{User: "u"}}, ``},
+		{"oauth value type", M{"Auth": dict(M{"kind": starlark.String("oauth"), "token": starlark.String("t")})}, S{Auth: oauth{Token: "t"}}, ``},
+		{"only kind", M{"Auth": dict(M{"kind": starlark.String("mtls")})}, S{Auth: &mtls{}}, ``},
+		{"pointer", M{"AuthPtr": dict(M{"kind": starlark.String("mtls")})}, S{AuthPtr: authptr(&mtls{})}, ``},
+		{"None", M{"Auth": starlark.None}, S{}, ``},
+		{"slice", M{"Auths": list(dict(M{"kind": starlark.String("mtls")}), dict(M{"kind": starlark.String("basic")}))}, S{Auths: []auth{&mtls{}, &basicAuth{}}}, ``},
+		{"not registered", M{"Any": dict(M{"kind": starlark.String("mtls")})}, S{Any: map[string]any{"kind": "mtls"}}, ``},
+		{"missing kind", M{"Auth": dict(M{"user": starlark.String("u")})}, S{}, `Auth: cannot convert Starlark dict to Go type starstruct.auth: missing discriminator key "kind" (allowed kinds: basic, mtls, oauth)`},
+		{"unknown kind", M{"Auth": dict(M{"kind": starlark.String("x")})}, S{}, `Auth: cannot convert Starlark dict to Go type starstruct.auth: invalid kind "x" for discriminator key "kind" (allowed kinds: basic, mtls, oauth)`},
+		{"kind not a string", M{"Auth": dict(M{"kind": starlark.MakeInt(1)})}, S{}, `Auth: cannot convert Starlark dict to Go type starstruct.auth: invalid kind 1 for discriminator key "kind" (allowed kinds: basic, mtls, oauth)`},
+		{"invalid field", M{"Auth": dict(M{"kind": starlark.String("basic"), "user": starlark.MakeInt(1)})}, S{Auth: &basicAuth{}}, `Auth.User: cannot convert Starlark int to Go type string`},
+		{"not a dict", M{"Auth": starlark.String("basic")}, S{}, `Auth: cannot convert Starlark string to Go type starstruct.auth`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var s S
+			err := FromStarlark(c.vals, &s, UnionFromRegistry(newAuthRegistry()))
+			if c.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, c.want, s)
+		})
+	}
+}
+
+func TestUnionFromStarlark_UnionError(t *testing.T) {
+	var s struct{ Auth auth }
+	err := FromStarlark(M{"Auth": dict(M{"kind": starlark.String("x")})}, &s, UnionFromRegistry(newAuthRegistry()))
+
+	var ue *UnionError
+	require.ErrorAs(t, err, &ue)
+	require.Equal(t, "Auth", ue.Path)
+	require.Equal(t, "kind", ue.Key)
+	require.Equal(t, starlark.String("x"), ue.Kind)
+	require.Equal(t, []string{"basic", "mtls", "oauth"}, ue.Allowed)
+}
+
+func TestUnionToStarlark(t *testing.T) {
+	type S struct {
+		Auth  auth
+		Other any
+	}
+
+	m := M{}
+	err := ToStarlark(S{Auth: &basicAuth{User: "u", Pwd: "p"}, Other: &basicAuth{}}, m, UnionToRegistry(newAuthRegistry()))
+	require.NoError(t, err)
+
+	got := m["Auth"].(*starlark.Dict)
+	require.Equal(t, []starlark.Value{starlark.String("kind"), starlark.String("user"), starlark.String("password")}, got.Keys())
+	require.Equal(t, starlark.StringDict{
+		"kind":     starlark.String("basic"),
+		"user":     starlark.String("u"),
+		"password": starlark.String("p"),
+	}, toStrDict(got))
+
+	// not registered for any
+	other := m["Other"].(*starlark.Dict)
+	require.Equal(t, []starlark.Value{starlark.String("user"), starlark.String("password")}, other.Keys())
+}
+
+func TestUnionRoundTrip(t *testing.T) {
+	type S struct {
+		Auths []auth
+	}
+
+	reg := newAuthRegistry()
+	in := S{Auths: []auth{&basicAuth{User: "u"}, oauth{Token: "t", Scopes: []string{"a"}}, &mtls{}}}
+	m := M{}
+	require.NoError(t, ToStarlark(in, m, UnionToRegistry(reg)))
+
+	var out S
+	require.NoError(t, FromStarlark(m, &out, UnionFromRegistry(reg)))
+	require.Equal(t, in, out)
+}
+
+func TestUnionRoundTrip_AsStruct(t *testing.T) {
+	type S struct {
+		Auths []auth
+	}
+
+	reg := newAuthRegistry()
+	in := S{Auths: []auth{&basicAuth{User: "u"}, oauth{Token: "t", Scopes: []string{"a"}}}}
+	m := M{}
+	require.NoError(t, ToStarlark(in, m, UnionToRegistry(reg), EncodeStructsAs(StructAsStruct)))
+
+	auths := m["Auths"].(*starlark.List)
+	require.IsType(t, (*starlarkstruct.Struct)(nil), auths.Index(0))
+	kind, err := auths.Index(0).(*starlarkstruct.Struct).Attr("kind")
+	require.NoError(t, err)
+	require.Equal(t, starlark.String("basic"), kind)
+
+	var out S
+	require.NoError(t, FromStarlark(m, &out, UnionFromRegistry(reg)))
+	require.Equal(t, in, out)
+}
+
+func TestUnionRegistry_Register(t *testing.T) {
+	authTyp := reflect.TypeOf((*auth)(nil)).Elem()
+
+	require.PanicsWithValue(t, `union type is not an interface: starstruct.mtls`, func() {
+		NewUnionRegistry().Register(reflect.TypeOf(mtls{}), "kind", nil)
+	})
+	require.PanicsWithValue(t, `union discriminator key is empty: starstruct.auth`, func() {
+		NewUnionRegistry().Register(authTyp, "", nil)
+	})
+	require.PanicsWithValue(t, `union kind "mtls": type starstruct.mtls does not implement starstruct.auth`, func() {
+		NewUnionRegistry().Register(authTyp, "kind", map[string]reflect.Type{"mtls": reflect.TypeOf(mtls{})})
+	})
+	require.Panics(t, func() {
+		NewUnionRegistry().Register(authTyp, "kind", map[string]reflect.Type{
+			"a": reflect.TypeOf(oauth{}),
+			"b": reflect.TypeOf(oauth{}),
+		})
+	})
+	require.PanicsWithValue(t, `union type is already registered: starstruct.auth`, func() {
+		reg := newAuthRegistry()
+		reg.Register(authTyp, "kind", nil)
+	})
+}
+
+func authptr(a auth) *auth { return &a }
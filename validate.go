@@ -0,0 +1,426 @@
+package starstruct
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidateConstraints enables the validation of the constraints specified
+// in struct tags (such as `starlark:"port,min=1"`) when converting Go values
+// to starlark. By default, those constraints are only validated by
+// FromStarlark. See FromStarlark for the list of supported constraints.
+func ValidateConstraints() ToOption {
+	return func(e *encoder) {
+		e.validate = true
+	}
+}
+
+// fieldOpt is a struct tag option that applies to the field itself (such as
+// a validation constraint), as opposed to the conversion options that apply
+// to its value and the nested values.
+type fieldOpt struct {
+	name string
+	arg  string
+}
+
+func (o fieldOpt) String() string {
+	if o.arg == "" {
+		return o.name
+	}
+	return o.name + "=" + o.arg
+}
+
+// names of the field options, with true for those that are validation
+// constraints.
+var fieldOptNames = map[string]bool{
+	"required": false,
+	"posonly":  false,
+	"variadic": false,
+	"default":  false,
+	"remain":   false,
+	"nonempty": true,
+	"min":      true,
+	"max":      true,
+	"len":      true,
+	"minlen":   true,
+	"maxlen":   true,
+	"oneof":    true,
+	"regexp":   true,
+}
+
+// field options whose value extends to the next of those options or the end
+// of the struct tag options, so that it may contain commas.
+var restFieldOpts = []string{"default=", "regexp="}
+
+// splits the raw, comma-separated struct tag options into the conversion
+// options and the field options. Field options can be set anywhere in the
+// struct tag options, except for the default and regexp options which must be
+// the last ones (in any order), as their value extends to the next one of
+// them or the end of the options (so that it may contain commas). The
+// remaining conversion options keep their order.
+func parseTagOpts(rawOpts string) (opts tagOpt, fopts []fieldOpt) {
+	for rawOpts != "" {
+		var opt string
+		if hasRestFieldOpt(rawOpts) {
+			opt, rawOpts = cutRestFieldOpt(rawOpts)
+		} else {
+			opt, rawOpts, _ = strings.Cut(rawOpts, ",")
+		}
+		name, arg, _ := strings.Cut(opt, "=")
+		if _, ok := fieldOptNames[name]; ok {
+			fopts = append(fopts, fieldOpt{name: name, arg: arg})
+			continue
+		}
+		opts = append(opts, opt)
+	}
+	return opts, fopts
+}
+
+func hasRestFieldOpt(rawOpts string) bool {
+	for _, prefix := range restFieldOpts {
+		if strings.HasPrefix(rawOpts, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cuts the raw options that start with a rest field option at the start of
+// the next rest field option, if any.
+func cutRestFieldOpt(rawOpts string) (opt, rest string) {
+	end := len(rawOpts)
+	for _, prefix := range restFieldOpts {
+		if ix := strings.Index(rawOpts, ","+prefix); ix >= 0 && ix < end {
+			end = ix
+		}
+	}
+	if end == len(rawOpts) {
+		return rawOpts, ""
+	}
+	return rawOpts[:end], rawOpts[end+1:]
+}
+
+// returns the field option with that name, if any.
+func findFieldOpt(fopts []fieldOpt, name string) (fieldOpt, bool) {
+	for _, o := range fopts {
+		if o.name == name {
+			return o, true
+		}
+	}
+	return fieldOpt{}, false
+}
+
+// validates the Go value v against the constraints in fopts, calling fail
+// for each constraint that is not satisfied, with a non-nil error if the
+// constraint is invalid or does not apply to the type of v.
+func validateConstraints(v reflect.Value, fopts []fieldOpt, fail func(c fieldOpt, err error)) {
+	for _, c := range fopts {
+		if !fieldOptNames[c.name] {
+			continue
+		}
+		ok, err := satisfies(v, c)
+		if err != nil || !ok {
+			fail(c, err)
+		}
+	}
+}
+
+// cache of the results of checkTags, by type.
+var checkedTags sync.Map
+
+// checks the struct tag options of the fields of the struct type typ and of
+// the struct types it contains, so that an invalid option is reported once,
+// when a builtin or binding is created for that type, instead of during each
+// conversion. It returns a TagError for the first invalid option found.
+func checkTags(typ reflect.Type) error {
+	if err, ok := checkedTags.Load(typ); ok {
+		err, _ := err.(error)
+		return err
+	}
+	err := checkTypeTags("", typ, make(map[reflect.Type]bool))
+	checkedTags.Store(typ, err)
+	return err
+}
+
+func checkTypeTags(path string, typ reflect.Type, seen map[reflect.Type]bool) error {
+	switch typ.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return checkTypeTags(path, typ.Elem(), seen)
+	case reflect.Struct:
+	default:
+		return nil
+	}
+	if seen[typ] {
+		return nil
+	}
+	seen[typ] = true
+
+	if _, _, err := remainField(path, reflect.New(typ).Elem()); err != nil {
+		return err
+	}
+
+	count := typ.NumField()
+	for i := 0; i < count; i++ {
+		fldTyp := typ.Field(i)
+		nm, rawOpts, _ := strings.Cut(fldTyp.Tag.Get("starlark"), ",")
+		if !fldTyp.IsExported() || nm == "-" {
+			continue
+		}
+
+		path := path
+		if path != "" {
+			path += "."
+		}
+		path += fldTyp.Name
+
+		_, fopts := parseTagOpts(rawOpts)
+		if err := checkFieldOpts(path, fldTyp.Type, fopts); err != nil {
+			return err
+		}
+		if err := checkTypeTags(path, fldTyp.Type, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// panics if the struct tag options of typ are invalid, see checkTags.
+func mustCheckTags(typ reflect.Type) {
+	if err := checkTags(typ); err != nil {
+		panic(fmt.Sprintf("invalid struct tag in type %s: %v", typ, err))
+	}
+}
+
+// checks the field options fopts of the field at path of type typ.
+func checkFieldOpts(path string, typ reflect.Type, fopts []fieldOpt) error {
+	zero := reflect.Zero(typ)
+	if typ.Kind() == reflect.Pointer {
+		// a nil pointer satisfies most constraints without checking them
+		zero = reflect.Zero(typ.Elem())
+	}
+	var err error
+	validateConstraints(zero, fopts, func(c fieldOpt, cerr error) {
+		if err == nil && cerr != nil {
+			err = &TagError{Path: path, Option: c.String(), Err: cerr}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if def, ok := findFieldOpt(fopts, "default"); ok {
+		if _, err := defaultValue(def.arg); err != nil {
+			return &TagError{Path: path, Option: def.String(), Err: err}
+		}
+	}
+	return nil
+}
+
+// returns true if v satisfies the constraint c. A nil pointer only fails the
+// nonempty constraint.
+func satisfies(v reflect.Value, c fieldOpt) (bool, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return c.name != "nonempty", nil
+		}
+		v = v.Elem()
+	}
+
+	switch c.name {
+	case "nonempty":
+		switch v.Kind() {
+		case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+			return v.Len() > 0, nil
+		}
+		return !v.IsZero(), nil
+
+	case "min", "max":
+		cmp, err := compareNum(v, c.arg)
+		if err != nil {
+			return false, err
+		}
+		if c.name == "min" {
+			return cmp >= 0, nil
+		}
+		return cmp <= 0, nil
+
+	case "len", "minlen", "maxlen":
+		n, err := strconv.Atoi(c.arg)
+		if err != nil {
+			return false, err
+		}
+		var l int
+		switch v.Kind() {
+		case reflect.String:
+			l = utf8.RuneCountInString(v.String())
+		case reflect.Slice, reflect.Map, reflect.Array:
+			l = v.Len()
+		default:
+			return false, fmt.Errorf("not a string, slice, array or map: %s", v.Type())
+		}
+		switch c.name {
+		case "len":
+			return l == n, nil
+		case "minlen":
+			return l >= n, nil
+		default:
+			return l <= n, nil
+		}
+
+	case "oneof":
+		switch v.Kind() {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		default:
+			return false, fmt.Errorf("not a string, bool or number: %s", v.Type())
+		}
+		s := fmt.Sprint(v)
+		for _, allowed := range strings.Split(c.arg, "|") {
+			if s == allowed {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "regexp":
+		if v.Kind() != reflect.String {
+			return false, fmt.Errorf("not a string: %s", v.Type())
+		}
+		re, err := compileRegexp(c.arg)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(v.String()), nil
+
+	default:
+		return false, fmt.Errorf("unknown constraint")
+	}
+}
+
+// compares the number v to the number parsed from arg, returning -1, 0 or
+// +1 if v is less than, equal to or greater than arg. A time.Duration is
+// compared to a duration such as "1s".
+func compareNum(v reflect.Value, arg string) (int, error) {
+	cmp := func(a, b float64) int {
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return 0, err
+		}
+		return cmp(float64(v.Int()), float64(d)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		switch x := v.Int(); {
+		case x < n:
+			return -1, nil
+		case x > n:
+			return 1, nil
+		}
+		return 0, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if strings.HasPrefix(arg, "-") {
+			if _, err := strconv.ParseInt(arg, 10, 64); err != nil {
+				return 0, err
+			}
+			return 1, nil
+		}
+		n, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		switch x := v.Uint(); {
+		case x < n:
+			return -1, nil
+		case x > n:
+			return 1, nil
+		}
+		return 0, nil
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return 0, err
+		}
+		return cmp(v.Float(), f), nil
+
+	default:
+		return 0, fmt.Errorf("not a number: %s", v.Type())
+	}
+}
+
+// cache of the compiled regexp constraints.
+var regexps sync.Map
+
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	if re, ok := regexps.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexps.Store(expr, re)
+	return re, nil
+}
+
+func (d *decoder) validateField(path string, fld reflect.Value, fopts []fieldOpt) {
+	validateConstraints(fld, fopts, func(c fieldOpt, err error) {
+		if err != nil {
+			d.recordErr(&TagError{Path: path, Option: c.String(), Err: err})
+			return
+		}
+		d.recordValidationErr(path, fld, c)
+	})
+}
+
+func (e *encoder) validateField(path string, fld reflect.Value, fopts []fieldOpt) {
+	validateConstraints(fld, fopts, func(c fieldOpt, err error) {
+		if err != nil {
+			e.recordErr(&TagError{Path: path, Option: c.String(), Err: err})
+			return
+		}
+		e.recordValidationErr(path, fld, c)
+	})
+}
+
+func (d *decoder) recordValidationErr(path string, goVal reflect.Value, c fieldOpt) {
+	err := &ValidationError{
+		Op:    OpFromStarlark,
+		Path:  path,
+		Rule:  c.String(),
+		GoVal: goVal,
+	}
+	d.recordErr(err)
+}
+
+func (e *encoder) recordValidationErr(path string, goVal reflect.Value, c fieldOpt) {
+	err := &ValidationError{
+		Op:    OpToStarlark,
+		Path:  path,
+		Rule:  c.String(),
+		GoVal: goVal,
+	}
+	e.recordErr(err)
+}
@@ -0,0 +1,250 @@
+package starstruct
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.starlark.net/starlark"
+)
+
+type validServer struct {
+	Name    string            `starlark:"name,nonempty,maxlen=5"`
+	Port    int               `starlark:"port,min=1,max=65535"`
+	Weight  *float64          `starlark:"weight,min=0.5"`
+	Proto   string            `starlark:"proto,oneof=tcp|udp"`
+	Code    uint8             `starlark:"code,oneof=1|2,max=10"`
+	Host    string            `starlark:"host,regexp=^[a-z]+$"`
+	Tags    []string          `starlark:"tags,minlen=1,asset"`
+	Pair    []int             `starlark:"pair,len=2"`
+	Labels  map[string]string `starlark:"labels,maxlen=1"`
+	Timeout time.Duration     `starlark:"timeout,min=1s,asint=ms"`
+}
+
+func TestFromStarlark_Constraints(t *testing.T) {
+	weight := 1.5
+	valid := M{
+		"name":    starlark.String("héllo"),
+		"port":    starlark.MakeInt(80),
+		"weight":  starlark.Float(weight),
+		"proto":   starlark.String("udp"),
+		"code":    starlark.MakeInt(2),
+		"host":    starlark.String("abc"),
+		"tags":    set(starlark.String("a")),
+		"pair":    list(starlark.MakeInt(1), starlark.MakeInt(2)),
+		"labels":  dict(M{"a": starlark.String("b")}),
+		"timeout": starlark.MakeInt(1000),
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		var out validServer
+		require.NoError(t, FromStarlark(valid, &out))
+		require.Equal(t, validServer{
+			Name:    "héllo",
+			Port:    80,
+			Weight:  &weight,
+			Proto:   "udp",
+			Code:    2,
+			Host:    "abc",
+			Tags:    []string{"a"},
+			Pair:    []int{1, 2},
+			Labels:  map[string]string{"a": "b"},
+			Timeout: time.Second,
+		}, out)
+	})
+
+	cases := []struct {
+		key string
+		val starlark.Value
+		err string
+	}{
+		{"name", starlark.String(""), `Name: value "" does not satisfy nonempty`},
+		{"name", starlark.String("abcdef"), `Name: value "abcdef" does not satisfy maxlen=5`},
+		{"port", starlark.MakeInt(0), `Port: value 0 does not satisfy min=1`},
+		{"port", starlark.MakeInt(65536), `Port: value 65536 does not satisfy max=65535`},
+		{"weight", starlark.Float(0.1), `Weight: value 0.1 does not satisfy min=0.5`},
+		{"proto", starlark.String("http"), `Proto: value "http" does not satisfy oneof=tcp|udp`},
+		{"code", starlark.MakeInt(3), `Code: value 3 does not satisfy oneof=1|2`},
+		{"host", starlark.String("a1"), `Host: value "a1" does not satisfy regexp=^[a-z]+$`},
+		{"tags", set(), `Tags: value [] does not satisfy minlen=1`},
+		{"pair", list(starlark.MakeInt(1)), `Pair: value [1] does not satisfy len=2`},
+		{"labels", dict(M{"a": starlark.String("b"), "c": starlark.String("d")}), `Labels: value map[a:b c:d] does not satisfy maxlen=1`},
+		{"timeout", starlark.MakeInt(999), `Timeout: value 999ms does not satisfy min=1s`},
+	}
+	for _, c := range cases {
+		t.Run(c.err, func(t *testing.T) {
+			vals := make(M, len(valid))
+			for k, v := range valid {
+				vals[k] = v
+			}
+			vals[c.key] = c.val
+
+			var out validServer
+			err := FromStarlark(vals, &out)
+			require.EqualError(t, err, c.err)
+			var ve *ValidationError
+			require.ErrorAs(t, err, &ve)
+			require.Equal(t, OpFromStarlark, ve.Op)
+		})
+	}
+
+	t.Run("nil pointer", func(t *testing.T) {
+		var out struct {
+			P *int `starlark:"p,min=1"`
+			N *int `starlark:"n,nonempty"`
+		}
+		err := FromStarlark(M{"p": starlark.None, "n": starlark.None}, &out)
+		require.EqualError(t, err, "N: value <nil> does not satisfy nonempty")
+	})
+
+	t.Run("not validated on conversion error", func(t *testing.T) {
+		var out validServer
+		err := FromStarlark(M{"port": starlark.String("x")}, &out)
+		require.EqualError(t, err, "Port: cannot convert Starlark string to Go type int")
+	})
+
+	t.Run("max errors", func(t *testing.T) {
+		var out validServer
+		err := FromStarlark(M{"name": starlark.String(""), "port": starlark.MakeInt(0)}, &out, MaxFromErrors(1))
+		require.Error(t, err)
+		errs := err.(interface{ Unwrap() []error }).Unwrap()
+		require.Len(t, errs, 2)
+		require.EqualError(t, errs[1], "maximum number of errors reached")
+	})
+}
+
+func TestFromStarlark_InvalidConstraints(t *testing.T) {
+	t.Run("invalid number", func(t *testing.T) {
+		var out struct {
+			Port int `starlark:"port,min=x"`
+		}
+		err := FromStarlark(M{"port": starlark.MakeInt(1)}, &out)
+		require.EqualError(t, err, `Port: invalid tag option min=x: strconv.ParseInt: parsing "x": invalid syntax`)
+		var te *TagError
+		require.ErrorAs(t, err, &te)
+		require.Equal(t, "min=x", te.Option)
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		var out struct {
+			B bool `starlark:"b,regexp=a"`
+		}
+		err := FromStarlark(M{"b": starlark.True}, &out)
+		require.EqualError(t, err, "B: invalid tag option regexp=a: not a string: bool")
+	})
+
+	t.Run("to starlark", func(t *testing.T) {
+		in := struct {
+			S string `starlark:"s,min=1"`
+		}{S: "a"}
+		err := ToStarlark(in, make(starlark.StringDict), ValidateConstraints())
+		require.EqualError(t, err, "S: invalid tag option min=1: not a number: string")
+	})
+}
+
+func TestCheckTags(t *testing.T) {
+	type inner struct {
+		Code string `starlark:"code,regexp=("`
+	}
+	type outer struct {
+		Name  string `starlark:"name,nonempty,maxlen=10"`
+		Port  *int   `starlark:"port,min=1"`
+		Items []inner
+	}
+	type bad struct {
+		Port *string `starlark:"port,min=1"`
+	}
+
+	require.NoError(t, checkTags(reflect.TypeOf(struct{ Name string }{})))
+	require.EqualError(t, checkTags(reflect.TypeOf(outer{})), "Items.Code: invalid tag option regexp=(: error parsing regexp: missing closing ): `(`")
+	require.EqualError(t, checkTags(reflect.TypeOf(bad{})), "Port: invalid tag option min=1: not a number: string")
+
+	require.PanicsWithValue(t, "invalid struct tag in type starstruct.bad: Port: invalid tag option min=1: not a number: string", func() {
+		Bind(&bad{})
+	})
+	require.PanicsWithValue(t, "invalid struct tag in type starstruct.bad: Port: invalid tag option min=1: not a number: string", func() {
+		Constructor(reflect.TypeOf(bad{}))
+	})
+	require.PanicsWithValue(t, "invalid struct tag in type *starstruct.bad: Port: invalid tag option min=1: not a number: string", func() {
+		NewCollector[*bad]("bad")
+	})
+	require.PanicsWithValue(t, "invalid struct tag in type starstruct.bad: Port: invalid tag option min=1: not a number: string", func() {
+		Func(func(b bad) {})
+	})
+}
+
+func TestFromStarlark_TagOpts(t *testing.T) {
+	t.Run("regexp with commas", func(t *testing.T) {
+		var out struct {
+			Code string `starlark:"code,nonempty,regexp=^[a-z]{1,3}$"`
+		}
+		require.NoError(t, FromStarlark(M{"code": starlark.String("abc")}, &out))
+		require.Equal(t, "abc", out.Code)
+
+		err := FromStarlark(M{"code": starlark.String("abcd")}, &out)
+		require.EqualError(t, err, `Code: value "abcd" does not satisfy regexp=^[a-z]{1,3}$`)
+	})
+
+	t.Run("unknown option", func(t *testing.T) {
+		// unknown options are ignored
+		var out struct {
+			L []int `starlark:"l,omitempty"`
+		}
+		require.NoError(t, FromStarlark(M{"l": list(starlark.MakeInt(1))}, &out))
+		require.Equal(t, []int{1}, out.L)
+	})
+}
+
+func TestToStarlark_TagOpts(t *testing.T) {
+	t.Run("unknown option with custom converter", func(t *testing.T) {
+		// unknown options are passed to the custom converter
+		in := struct {
+			I int `starlark:"i,someopt"`
+		}{I: 1}
+		var gotOpts []string
+		custom := func(path string, goVal reflect.Value, tagOpts []string) (starlark.Value, error) {
+			if path == "I" {
+				gotOpts = tagOpts
+			}
+			return nil, nil
+		}
+		m := M{}
+		require.NoError(t, ToStarlark(in, m, CustomToConverter(custom)))
+		require.Equal(t, []string{"someopt"}, gotOpts)
+		require.Equal(t, M{"i": starlark.MakeInt(1)}, m)
+	})
+}
+
+func TestToStarlark_Constraints(t *testing.T) {
+	in := struct {
+		Port int `starlark:"port,min=1"`
+	}{}
+
+	require.NoError(t, ToStarlark(in, nil))
+
+	m := M{}
+	err := ToStarlark(in, m, ValidateConstraints())
+	require.EqualError(t, err, "Port: value 0 does not satisfy min=1")
+	var ve *ValidationError
+	require.ErrorAs(t, err, &ve)
+	require.Equal(t, OpToStarlark, ve.Op)
+	require.Equal(t, "min=1", ve.Rule)
+	require.Equal(t, M{"port": starlark.MakeInt(0)}, m)
+}
+
+func TestBind_Constraints(t *testing.T) {
+	var srv validServer
+	var th starlark.Thread
+	_, err := starlark.ExecFile(&th, "test", "srv.port = 0", starlark.StringDict{"srv": Bind(&srv)})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Port: value 0 does not satisfy min=1")
+}
+
+func TestUnpackArgs_Constraints(t *testing.T) {
+	var args struct {
+		Port int `starlark:"port,required,min=1"`
+	}
+	err := UnpackArgs("fn", starlark.Tuple{starlark.MakeInt(0)}, nil, &args)
+	require.EqualError(t, err, "fn: for parameter port: Port: value 0 does not satisfy min=1")
+}